@@ -0,0 +1,538 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"retro/emu"
+	"retro/emu/config"
+	"retro/emu/device/diskette"
+	"retro/emu/device/diskette/dos33"
+	"retro/emu/device/diskette/prodos"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// diskCommands are the subcommands of "retro disk".
+var diskCommands = map[string]func(conf *config.Config, args []string) error{
+	"new":     diskNew,
+	"catalog": diskCatalog,
+	"get":     diskGet,
+	"put":     diskPut,
+	"info":    diskInfo,
+	"ls":      diskList,
+	"mkdir":   diskMkdir,
+}
+
+// disk runs a disk image subcommand.
+func disk(conf *config.Config, args []string) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "help" {
+		_, _ = fmt.Fprint(os.Stderr, diskHelp)
+		return nil
+	}
+	cmd, ok := diskCommands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown disk command %q, see: retro disk help", args[0])
+	}
+	err := cmd(conf, args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return err
+}
+
+// diskNew creates a blank 140K image, optionally with a DOS 3.3
+// or a ProDOS file system.
+func diskNew(_ *config.Config, args []string) error {
+	flags := newFlagSet("new")
+	dos := flags.Bool("dos", false, "")
+	volume := flags.Int("volume", dos33.DefaultVolume, "")
+	name := flags.String("prodos", "", "")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 || *dos && *name != "" {
+		return errors.New("usage: retro disk new [-dos] [-volume <1..254>] [-prodos <name>] <image>")
+	}
+	if *volume < 1 || *volume > 254 {
+		return fmt.Errorf("volume %d, expected 1..254", *volume)
+	}
+
+	path := flags.Arg(0)
+	image := diskette.NewBlankImage(diskette.DetectOrder(path, nil))
+
+	if *dos {
+		if err := dos33.Format(image, byte(*volume)); err != nil {
+			return err
+		}
+	}
+	if *name != "" {
+		if err := prodos.Format(image, *name); err != nil {
+			return err
+		}
+	}
+
+	// Never overwrite an existing image.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err = image.WriteTo(file); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// bootLoaders are signatures of the boot sector (track 0, sector 0),
+// loaded to $0800 by the Disk II boot ROM.
+var bootLoaders = []struct {
+	name string
+	sig  []byte
+}{
+	{"DOS 3.3", []byte{0x01, 0xA5, 0x27, 0xC9, 0x09, 0xD0}},
+	{"ProDOS", []byte{0x01, 0x38, 0xB0, 0x03, 0x4C}},
+}
+
+// diskInfo prints format, sector order, file system and boot sector of an image.
+func diskInfo(conf *config.Config, args []string) error {
+	flags := newFlagSet("info")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: retro disk info <image>")
+	}
+
+	path := flags.Arg(0)
+	image, err := emu.LoadImage(path, conf)
+	if err != nil {
+		return err
+	}
+
+	format := string(image.Format())
+	if c := image.Container(); c != "" {
+		format = fmt.Sprintf("%s, %s data", c, format)
+	}
+	fmt.Printf("Image:        %s\n", path)
+	fmt.Printf("Format:       %s\n", format)
+	if image.Format() == diskette.FormatDSK {
+		fmt.Printf("Order:        %s\n", image.Order())
+	}
+
+	fs := "unknown"
+	switch {
+	case image.Format() == diskette.FormatD13:
+		fs = "DOS 3.2 or 3.1 (13 sector)"
+	default:
+		if dos, err := dos33.Open(image); err == nil {
+			fs = fmt.Sprintf("DOS 3.3, volume %d", dos.VTOC().Volume())
+		} else if vol, err := prodos.Open(image); err == nil {
+			free, _ := vol.Free()
+			fs = fmt.Sprintf("ProDOS, volume /%s, %d blocks free", vol.Name(), free)
+		}
+	}
+	fmt.Printf("File system:  %s\n", fs)
+
+	boot := "unknown"
+	if sec, err := image.ReadSector(0, 0, diskette.DOSOrder); err != nil {
+		boot = err.Error()
+	} else {
+		boot = fmt.Sprintf("unknown, % X", sec[:8])
+		if bytes.Equal(sec, make([]byte, len(sec))) {
+			boot = "empty, not bootable"
+		}
+		for _, loader := range bootLoaders {
+			if bytes.HasPrefix(sec, loader.sig) {
+				boot = loader.name
+			}
+		}
+	}
+	fmt.Printf("Boot sector:  %s\n", boot)
+
+	meta := image.Meta()
+	keys := make([]string, 0, len(meta))
+	for key := range meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("Meta:         %s: %s\n", key, meta[key])
+	}
+	return nil
+}
+
+// diskCatalog prints the catalog of a DOS 3.3 image, as DOS does,
+// or the volume directory of a ProDOS image.
+func diskCatalog(conf *config.Config, args []string) error {
+	flags := newFlagSet("catalog")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: retro disk catalog <image>")
+	}
+
+	dos, vol, _, err := openDisk(conf, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if vol != nil {
+		return listDir(vol, "/"+vol.Name(), false)
+	}
+	list, err := dos.Catalog()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nDISK VOLUME %d\n\n", dos.VTOC().Volume())
+	for _, e := range list {
+		fmt.Println(e)
+	}
+	return nil
+}
+
+// diskGet copies a file out of a DOS 3.3 or ProDOS image.
+func diskGet(conf *config.Config, args []string) error {
+	flags := newFlagSet("get")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 2 || flags.NArg() > 3 {
+		return errors.New("usage: retro disk get <image> <name> [<file>]")
+	}
+
+	dos, vol, _, err := openDisk(conf, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var name string
+	var data []byte
+
+	if vol != nil {
+		f, err := vol.ReadFile(flags.Arg(1))
+		if err != nil {
+			return err
+		}
+		switch f.Type {
+		case prodos.TypeTXT:
+			f.Data = prodos.ToText(f.Data)
+		case prodos.TypeBIN:
+			_, _ = fmt.Fprintf(os.Stderr, "%s: load address $%04X, %d bytes\n", f.Name, f.Aux, len(f.Data))
+		}
+		name, data = f.Name, f.Data
+	} else {
+		f, err := dos.ReadFile(strings.ToUpper(flags.Arg(1)))
+		if err != nil {
+			return err
+		}
+		switch f.Type.String() {
+		case "T":
+			f.Data = dos33.ToText(f.Data)
+		case "B":
+			_, _ = fmt.Fprintf(os.Stderr, "%s: load address $%04X, %d bytes\n", f.Name, f.Addr, len(f.Data))
+		}
+		name, data = f.Name, f.Data
+	}
+
+	path := name
+	if flags.NArg() == 3 {
+		path = flags.Arg(2)
+	}
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// diskPut copies a file into a DOS 3.3 or ProDOS image.
+func diskPut(conf *config.Config, args []string) error {
+	flags := newFlagSet("put")
+	kind := flags.String("type", "B", "")
+	addr := flags.String("addr", "", "")
+	name := flags.String("name", "", "")
+	lock := flags.Bool("lock", false, "")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return errors.New("usage: retro disk put [-type <type>] [-addr <address>] [-name <name>] [-lock] <image> <file>")
+	}
+
+	path := flags.Arg(1)
+	if *name == "" {
+		*name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	var data []byte
+	var err error
+
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+
+	load := uint16(0)
+	if *addr != "" {
+		num, err := strconv.ParseUint(strings.Replace(*addr, "$", "0x", 1), 0, 16)
+		if err != nil {
+			return fmt.Errorf("invalid load address %q", *addr)
+		}
+		load = uint16(num)
+	}
+
+	dos, vol, image, err := openDisk(conf, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if vol != nil {
+		err = putProDOS(vol, *name, *kind, *addr != "", load, *lock, data)
+	} else {
+		err = putDOS(dos, *name, *kind, *addr != "", load, *lock, data)
+	}
+	if err != nil {
+		return err
+	}
+	return emu.SaveImage(flags.Arg(0), image)
+}
+
+func putDOS(dos *dos33.Disk, name, kind string, hasAddr bool, addr uint16, lock bool, data []byte) error {
+	t, err := dos33.ParseType(kind)
+	if err != nil {
+		return err
+	}
+	f := &dos33.File{Name: name, Type: t, Locked: lock, Addr: addr, Data: data}
+
+	switch t {
+	case dos33.TypeBinary:
+		if !hasAddr {
+			return errors.New("binary files require a load address (-addr)")
+		}
+	case dos33.TypeText:
+		f.Data = dos33.FromText(f.Data)
+	}
+	return dos.WriteFile(f)
+}
+
+// putProDOS stores the file in the directory of the name,
+// e.g. "GAMES/HELLO", relative to the volume directory.
+func putProDOS(vol *prodos.Volume, name, kind string, hasAddr bool, addr uint16, lock bool, data []byte) error {
+	t, err := prodos.ParseType(kind)
+	if err != nil {
+		return err
+	}
+	dir, base := path.Split(name)
+	f := &prodos.File{Name: base, Type: t, Locked: lock, Aux: int(addr), Data: data}
+
+	switch t {
+	case prodos.TypeBIN:
+		if !hasAddr {
+			return errors.New("binary files require a load address (-addr)")
+		}
+	case prodos.TypeBAS:
+		if !hasAddr {
+			f.Aux = 0x0801
+		}
+	case prodos.TypeSYS:
+		if !hasAddr {
+			f.Aux = 0x2000
+		}
+	case prodos.TypeTXT:
+		f.Data = prodos.FromText(f.Data)
+	}
+	return vol.WriteFile(dir, f)
+}
+
+// diskList lists a directory of a ProDOS image, recursively with -R.
+func diskList(conf *config.Config, args []string) error {
+	flags := newFlagSet("ls")
+	recurse := flags.Bool("R", false, "")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		return errors.New("usage: retro disk ls [-R] <image> [<path>]")
+	}
+
+	_, vol, _, err := openDisk(conf, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if vol == nil {
+		return fmt.Errorf("%s: %w, see: retro disk catalog", flags.Arg(0), prodos.ErrNoProDOS)
+	}
+
+	dir := "/" + vol.Name()
+	if flags.NArg() == 2 {
+		e, err := vol.Lookup(flags.Arg(1))
+		if err != nil {
+			return err
+		}
+		if !e.IsDir() {
+			fmt.Println(e)
+			return nil
+		}
+		if dir = flags.Arg(1); !strings.HasPrefix(dir, "/") {
+			dir = "/" + vol.Name() + "/" + dir
+		}
+	}
+	return listDir(vol, strings.ToUpper(path.Clean(dir)), *recurse)
+}
+
+// diskMkdir creates a subdirectory in a ProDOS image.
+func diskMkdir(conf *config.Config, args []string) error {
+	flags := newFlagSet("mkdir")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return errors.New("usage: retro disk mkdir <image> <path>")
+	}
+
+	_, vol, image, err := openDisk(conf, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if vol == nil {
+		return fmt.Errorf("%s: %w", flags.Arg(0), prodos.ErrNoProDOS)
+	}
+	if err = vol.Mkdir(flags.Arg(1)); err != nil {
+		return err
+	}
+	return emu.SaveImage(flags.Arg(0), image)
+}
+
+// listDir prints the directory at the absolute path, as CATALOG does.
+func listDir(vol *prodos.Volume, dir string, recurse bool) error {
+	list, err := vol.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%s\n\n NAME            TYPE BLOCKS  MODIFIED         ENDFILE  AUXTYPE\n\n", dir)
+	for _, e := range list {
+		fmt.Println(e)
+	}
+	if path.Dir(dir) == "/" {
+		free, err := vol.Free()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\nBLOCKS FREE: %d\n", free)
+	}
+
+	if recurse {
+		for _, e := range list {
+			if !e.IsDir() {
+				continue
+			}
+			if err = listDir(vol, path.Join(dir, e.Name), true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// openDisk loads the image and opens its DOS 3.3 or ProDOS file system.
+func openDisk(conf *config.Config, path string) (*dos33.Disk, *prodos.Volume, *diskette.Image, error) {
+	image, err := emu.LoadImage(path, conf)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	dos, err := dos33.Open(image)
+	if err == nil {
+		return dos, nil, image, nil
+	}
+	if !errors.Is(err, dos33.ErrNoDOS) {
+		return nil, nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	vol, err := prodos.Open(image)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: no DOS 3.3 or ProDOS file system", path)
+	}
+	return nil, vol, image, nil
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() { _, _ = fmt.Fprint(flags.Output(), diskHelp) }
+	return flags
+}
+
+// ---
+
+var diskHelp = `
+Usage: retro disk <command> [arguments]
+Disk image commands of the Retro Apple II Emulator
+
+Commands:
+    new [-dos] [-volume <1..254>] [-prodos <name>] <path/to/image>
+         Creates a blank 140K image with empty sectors. The sector
+         order is ProDOS for .po files, DOS otherwise. With -dos,
+         the image is formatted with an empty DOS 3.3 catalog and
+         the volume number (default 254). With -prodos, the image
+         is formatted as an empty ProDOS volume of the name. The
+         image contains no DOS and is not bootable. Existing files
+         are not replaced.
+
+    info <path/to/image>
+         Prints the format, the sector order, the file system and
+         the boot loader of an image, and the metadata of WOZ and
+         2IMG images. Invalid images are rejected with the reason.
+
+    catalog <path/to/image>
+         Prints the catalog of a DOS 3.3 image: locked flag, type,
+         size in sectors and name of the files, or the volume
+         directory of a ProDOS image. Path can be an HTTP URL.
+
+    ls [-R] <path/to/image> [<path>]
+         Lists a directory of a ProDOS image, the volume directory
+         unless provided. With -R, subdirectories are listed too.
+         ProDOS paths are separated by "/" and are relative to the
+         volume directory, unless they start with "/VOLUME-NAME".
+
+    mkdir <path/to/image> <path>
+         Creates a subdirectory in a ProDOS image.
+
+    get <path/to/image> <name> [<path/to/file>]
+         Copies a file out of a DOS 3.3 or ProDOS image, to a file
+         of the same name, unless provided. The path "-" is standard
+         output. The header of DOS 3.3 B, A and I files is removed,
+         the load address of binary files is printed. Text files are
+         converted to host text.
+
+    put [-type <type>] [-addr <address>] [-name <name>] [-lock]
+        <path/to/image> <path/to/file>
+         Copies a file into a DOS 3.3 or ProDOS image, replacing an
+         unlocked file of the same name. The name defaults to the
+         file name without extension, on ProDOS it can be a path.
+         The type is one of T, I, A, B (default) on DOS 3.3, on
+         ProDOS also a type name (TXT, BIN, SYS, ...) or number ($06).
+         Binary files require the load address, e.g. -addr $0803.
+         A and I files are expected to be tokenized. Host text is
+         converted for text files. The path "-" is standard input.
+
+`
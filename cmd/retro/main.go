@@ -19,6 +19,11 @@ type (
 		otherConfigFile  *string
 		image1FilePath   *string
 		image2FilePath   *string
+		imageOrder       *string
+		diskSet          *string
+		wavFilePath      *string
+		tapeFilePath     *string
+		tapeRecordPath   *string
 		cpuSpeedInMHz    *float64
 		justPrintVersion *bool
 		windowZoomLevel  *int
@@ -35,6 +40,17 @@ func main() {
 
 	version = fmt.Sprintf("%s (%s %s)", version, runtime.GOOS, runtime.GOARCH)
 
+	// Disk image commands do not start the emulator.
+	if len(os.Args) > 1 && os.Args[1] == "disk" {
+		conf := config.DefaultConfig
+		conf.Version = version
+
+		if err := disk(conf, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Load defaults, parse command line options and flags.
 	conf := config.DefaultConfig
 	opts := parseOpts()
@@ -69,6 +85,14 @@ func applyOpts(conf *config.Config, opts options) {
 		return
 	}
 
+	// Select a disk set, its first image goes into drive 1.
+	if *opts.diskSet != "" {
+		conf.Disk.Set = *opts.diskSet
+	}
+	if set := conf.DiskSets[conf.Disk.Set]; len(set) > 0 {
+		conf.Disk.Drive1 = set[0]
+	}
+
 	// Overwrite loaded disk config with provided image names.
 	if *opts.image1FilePath != "" {
 		conf.Disk.Drive1 = *opts.image1FilePath
@@ -76,6 +100,22 @@ func applyOpts(conf *config.Config, opts options) {
 	if *opts.image2FilePath != "" {
 		conf.Disk.Drive2 = *opts.image2FilePath
 	}
+	if *opts.imageOrder != "" {
+		conf.Disk.Order = *opts.imageOrder
+	}
+
+	// Record the sound.
+	if *opts.wavFilePath != "" {
+		conf.Audio.WAV = *opts.wavFilePath
+	}
+
+	// Cassette tape to play, and to record to.
+	if *opts.tapeFilePath != "" {
+		conf.Tape.Play = *opts.tapeFilePath
+	}
+	if *opts.tapeRecordPath != "" {
+		conf.Tape.Record = *opts.tapeRecordPath
+	}
 
 	// Overwrite loaded config with command line options.
 	if *opts.windowZoomLevel >= 1 && *opts.windowZoomLevel < 0x10 {
@@ -97,6 +137,11 @@ func parseOpts() options {
 		otherConfigFile:  flag.String("c", "retro.config.yml", ""),
 		image1FilePath:   flag.String("1", "", ""),
 		image2FilePath:   flag.String("2", "", ""),
+		imageOrder:       flag.String("o", "", ""),
+		diskSet:          flag.String("set", "", ""),
+		wavFilePath:      flag.String("wav", "", ""),
+		tapeFilePath:     flag.String("tape", "", ""),
+		tapeRecordPath:   flag.String("tape-rec", "", ""),
 		cpuSpeedInMHz:    flag.Float64("m", 0.98, ""),
 		justPrintVersion: flag.Bool("v", false, ""),
 		windowZoomLevel:  flag.Int("z", 3, ""),
@@ -109,6 +154,7 @@ func parseOpts() options {
 
 var help = `
 Usage: retro [options]
+       retro disk <command> [arguments]
 Retro Apple II Emulator %s
 
 The default name of the configuration file is "retro.config.yml".
@@ -117,19 +163,38 @@ first the directory, where the executable resides; then outside,
 in ~/.config/retro/, /usr/local/etc/retro/, and /etc/retro/
 
 When no disk image is provided (with -1), the emulator will boot
-into the Applesoft BASIC prompt. Additionally, the ROM(s) of the
-virtual Apple Disk II Interface will not be mounted into memory.
+into the Applesoft BASIC prompt. The boot ROM of the virtual Apple
+Disk II Interface is hidden, as long as drive 1 is empty, otherwise
+the machine would try to boot from the empty drive and hang, as a
+real Apple II does. The interface itself works for disks inserted
+later.
+
+Disk images can be swapped at runtime: drop an image file onto the
+left half of the window for drive 1, onto the right half for drive
+2. CTRL-ALT-1 and -2 eject the disk or insert it again. Boot a disk
+inserted later with PR#6.
+
+Disk images modified by the emulated machine are written back to
+their files on exit. Images fetched via HTTP, archived images, WOZ
+images and read-only files are write-protected for good. CTRL-SHIFT-1
+and -2 toggle the protection of the other images.
 
 Command line options override their configuration counterparts. 
 
 Options:
     -1 <path/to/image>
-         The APPLE DISK II .dsk image to insert into Drive 1.
-         Path can be a HTTP URL. Fetched images are not saved.
+         The APPLE DISK II image to insert into Drive 1, one of
+         .dsk, .do, .po, .d13, .nib, .woz or .2mg, also packed in
+         a .zip or .gz archive. Path can be an HTTP URL. Fetched
+         images are cached in ~/.cache/retro, the copy is used when
+         offline. Fetched and archived images are not saved.
     
     -2 <path/to/image>
-         The APPLE DISK II .dsk image to insert into Drive 2.
-         Path can be a HTTP URL. Fetched images are not saved.
+         The APPLE DISK II image to insert into Drive 2, one of
+         .dsk, .do, .po, .d13, .nib, .woz or .2mg, also packed in
+         a .zip or .gz archive. Path can be an HTTP URL. Fetched
+         images are cached in ~/.cache/retro, the copy is used when
+         offline. Fetched and archived images are not saved.
 
     -c <path/to/config>
          Path to an alternative configuration file. 
@@ -138,6 +203,33 @@ Options:
          MHz speed of the CPU clock. Usual clock settings are
          0.98 (1MHz) and 3.58 (4MHz). The first is the default.
 
+    -o <sector-order>
+         Sector order of the disk images: "auto", "dos", "prodos".
+         Auto detection is based on the file name extension (.do,
+         .po) and on the content. Default value: auto
+
+    -set <name>
+         Name of a disk set of the configuration file, e.g. the
+         sides of multi-disk software. The first image goes into
+         Drive 1, unless -1 is provided. CTRL-SHIFT-ALT-1 and -2
+         insert the next image of the set into Drive 1 and 2.
+
+    -tape <path/to/tape>
+         The tape in the cassette player, read by LOAD (Applesoft)
+         and R (Monitor): a .wav or .aif recording, a tokenized
+         Applesoft program (.bas, e.g. from "retro disk get") or
+         raw data (any other file). The tape runs, while it is
+         read, and is rewound after its end.
+
+    -tape-rec <path/to/file.wav>
+         Records the cassette output, written by SAVE (Applesoft)
+         and W (Monitor), to a WAV file.
+
+    -wav <path/to/file.wav>
+         Records the sound to a WAV file (stereo, 16 bit,
+         44.1 kHz), in addition to the playback. Works without
+         sound hardware.
+
     -z <window-zoom [1..n]>
          Window magnification. A zoom factor of 1 is equivalent
          to the Apple II native resolution of 280 x 192 pixels.
@@ -147,6 +239,9 @@ More options:
     -h  Display this usage help and exit.
     -v  Print program version and exit.
 
+Disk image commands:
+    retro disk help  Display the usage help of the disk commands.
+
 Sources: <https://github.com/dtgorski/retro>
 
 `
@@ -0,0 +1,88 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package emu
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// imageExtensions are the file name extensions of disk images.
+var imageExtensions = []string{".dsk", ".do", ".po", ".d13", ".nib", ".woz", ".2mg"}
+
+// unpack extracts the disk image from a .gz or .zip archive and returns
+// its name, which is used to detect the sector order. Other data is
+// returned unchanged. A .zip archive must contain a single disk image.
+func unpack(name string, data []byte) (string, []byte, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0x1F, 0x8B}):
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if data, err = io.ReadAll(reader); err != nil {
+			return "", nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return trimExt(name, ".gz"), data, nil
+
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		files := []*zip.File{}
+		for _, f := range archive.File {
+			base := path.Base(f.Name)
+			if f.FileInfo().IsDir() || strings.HasPrefix(base, ".") || strings.HasPrefix(f.Name, "__MACOSX/") {
+				continue
+			}
+			if isImageName(base) {
+				files = append(files, f)
+			}
+		}
+		if len(files) != 1 {
+			return "", nil, fmt.Errorf("%s: %d disk images in archive, expected one", name, len(files))
+		}
+
+		reader, err := files[0].Open()
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", name, err)
+		}
+		defer func() { _ = reader.Close() }()
+
+		if data, err = io.ReadAll(reader); err != nil {
+			return "", nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return files[0].Name, data, nil
+	}
+	return name, data, nil
+}
+
+// isArchive signals, whether the path names a .gz or .zip archive.
+func isArchive(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return ext == ".gz" || ext == ".zip"
+}
+
+func isImageName(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, e := range imageExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+func trimExt(name, ext string) string {
+	if strings.EqualFold(path.Ext(name), ext) {
+		return name[:len(name)-len(ext)]
+	}
+	return name
+}
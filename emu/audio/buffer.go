@@ -0,0 +1,110 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package audio
+
+import (
+	"sync"
+)
+
+type (
+	// Buffer decouples the emulated machine, producing samples in bursts
+	// of one batch of CPU steps followed by a sleep, from an audio device
+	// consuming them at a steady rate. The samples are resampled slightly
+	// faster or slower to keep the fill level at the target latency, so
+	// the emulated and the real clock may drift apart without gaps.
+	Buffer struct {
+		ring   []frame
+		head   int        // index of the oldest frame
+		size   int        // number of buffered frames
+		target int        // fill level to keep
+		pos    float64    // read position between the two oldest frames
+		last   [2]float64 // last frame read
+		primed bool       // false after an underrun, until the target is reached
+		mu     sync.Mutex
+	}
+
+	// frame is a pair of samples, left and right.
+	frame [2]int16
+)
+
+const (
+	// maxSkew limits the resampling ratio deviation. The emulated clock
+	// may deviate a few percent from real time, the buffer settles at
+	// a fill level below or above the target then.
+	maxSkew = 0.05
+
+	// fade is the decay of the last frame on underrun, to avoid clicks.
+	fade = 0.99
+)
+
+// NewBuffer creates a buffer, that keeps target frames in stock.
+// It holds four times as many, older frames are dropped on overflow.
+func NewBuffer(target int) *Buffer {
+	return &Buffer{
+		ring:   make([]frame, max(target, 1)<<2),
+		target: max(target, 1),
+	}
+}
+
+// Play appends the interleaved samples to the buffer.
+func (b *Buffer) Play(samples []int16) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(b.ring)
+	for i := 0; i+1 < len(samples); i += 2 {
+		if b.size == n {
+			b.head = (b.head + 1) % n
+			b.size--
+		}
+		b.ring[(b.head+b.size)%n] = frame{samples[i], samples[i+1]}
+		b.size++
+	}
+}
+
+// Close does nothing here.
+func (*Buffer) Close() error {
+	return nil
+}
+
+// Read fills out with resampled, interleaved samples. It always fills out
+// completely, on underrun with the fading last frame, and waits for the
+// buffer to reach the target level again before it continues.
+func (b *Buffer) Read(out []int16) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Proportional to the deviation from the target level.
+	ratio := 1 + maxSkew*float64(b.size-b.target)/float64(b.target)
+	ratio = max(1-maxSkew, min(1+maxSkew, ratio))
+
+	n := len(b.ring)
+	for i := 0; i+1 < len(out); i += 2 {
+		if !b.primed && b.size >= b.target {
+			b.primed = true
+		}
+		if b.primed && b.size < 2 {
+			b.primed = false
+		}
+
+		for c := range b.last {
+			if !b.primed {
+				b.last[c] *= fade
+			} else {
+				// Linear interpolation between the two oldest frames.
+				s0 := float64(b.ring[b.head][c])
+				s1 := float64(b.ring[(b.head+1)%n][c])
+				b.last[c] = s0 + (s1-s0)*b.pos
+			}
+			out[i+c] = int16(b.last[c])
+		}
+		if !b.primed {
+			continue
+		}
+
+		for b.pos += ratio; b.pos >= 1 && b.size > 1; b.pos-- {
+			b.head = (b.head + 1) % n
+			b.size--
+		}
+	}
+}
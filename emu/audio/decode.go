@@ -0,0 +1,153 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrFormat is returned for recordings, that are not decodable.
+var ErrFormat = errors.New("not a PCM WAV or AIFF recording")
+
+// Decode decodes a PCM WAV or AIFF recording. It returns the samples
+// of the first channel, scaled to 16 bit, and the sample rate.
+func Decode(data []byte) ([]int16, int, error) {
+	switch {
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE":
+		return decodeWAV(data[12:])
+	case len(data) >= 12 && string(data[0:4]) == "FORM" && string(data[8:12]) == "AIFF":
+		return decodeAIFF(data[12:])
+	}
+	return nil, 0, ErrFormat
+}
+
+// decodeWAV decodes the chunks of a WAV file (little endian).
+func decodeWAV(data []byte) ([]int16, int, error) {
+	var channels, bits, rate int
+	var pcm []byte
+
+	err := chunks(data, binary.LittleEndian, func(id string, chunk []byte) error {
+		switch id {
+		case "fmt ":
+			if len(chunk) < 16 {
+				return fmt.Errorf("%w: fmt chunk too short", ErrFormat)
+			}
+			// PCM or WAVE_FORMAT_EXTENSIBLE.
+			if tag := binary.LittleEndian.Uint16(chunk[0:]); tag != 1 && tag != 0xFFFE {
+				return fmt.Errorf("%w: compressed WAV format %d", ErrFormat, tag)
+			}
+			channels = int(binary.LittleEndian.Uint16(chunk[2:]))
+			rate = int(binary.LittleEndian.Uint32(chunk[4:]))
+			bits = int(binary.LittleEndian.Uint16(chunk[14:]))
+		case "data":
+			pcm = chunk
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if pcm == nil || rate == 0 {
+		return nil, 0, fmt.Errorf("%w: fmt or data chunk missing", ErrFormat)
+	}
+	// 8 bit WAV samples are unsigned.
+	return samples(pcm, channels, bits, binary.LittleEndian, bits == 8), rate, nil
+}
+
+// decodeAIFF decodes the chunks of an AIFF file (big endian).
+func decodeAIFF(data []byte) ([]int16, int, error) {
+	var channels, bits, rate int
+	var pcm []byte
+
+	err := chunks(data, binary.BigEndian, func(id string, chunk []byte) error {
+		switch id {
+		case "COMM":
+			if len(chunk) < 18 {
+				return fmt.Errorf("%w: COMM chunk too short", ErrFormat)
+			}
+			channels = int(binary.BigEndian.Uint16(chunk[0:]))
+			bits = int(binary.BigEndian.Uint16(chunk[6:]))
+			rate = int(extended(chunk[8:18]))
+		case "SSND":
+			if len(chunk) < 8 {
+				return fmt.Errorf("%w: SSND chunk too short", ErrFormat)
+			}
+			if offset := int(binary.BigEndian.Uint32(chunk[0:])); 8+offset <= len(chunk) {
+				pcm = chunk[8+offset:]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if pcm == nil || rate == 0 {
+		return nil, 0, fmt.Errorf("%w: COMM or SSND chunk missing", ErrFormat)
+	}
+	return samples(pcm, channels, bits, binary.BigEndian, false), rate, nil
+}
+
+// chunks calls fn for each chunk. Chunks are padded to an even size.
+func chunks(data []byte, order binary.ByteOrder, fn func(id string, chunk []byte) error) error {
+	for pos := 0; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(order.Uint32(data[pos+4:]))
+
+		if pos += 8; size < 0 || pos+size > len(data) {
+			// Recordings cut off while writing, the sizes are not updated.
+			if id != "data" && id != "SSND" {
+				return fmt.Errorf("%w: %s chunk exceeds file size", ErrFormat, id)
+			}
+			size = len(data) - pos
+		}
+		if err := fn(id, data[pos:pos+size]); err != nil {
+			return err
+		}
+		pos += size + size&0x01
+	}
+	return nil
+}
+
+// samples converts the first channel of PCM frames to 16 bit samples.
+func samples(pcm []byte, channels, bits int, order binary.ByteOrder, unsigned bool) []int16 {
+	width := (bits + 7) >> 3
+	if channels < 1 || width < 1 || width > 4 {
+		return nil
+	}
+	frame := channels * width
+	out := make([]int16, 0, len(pcm)/frame)
+
+	for pos := 0; pos+frame <= len(pcm); pos += frame {
+		var s int16
+		switch width {
+		case 1:
+			s = int16(int8(pcm[pos])) << 8
+			if unsigned {
+				s = int16(int8(pcm[pos]^0x80)) << 8
+			}
+		default:
+			// The most significant two bytes.
+			b := pcm[pos : pos+width]
+			if order == binary.LittleEndian {
+				b = b[width-2:]
+			}
+			s = int16(order.Uint16(b))
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// extended converts an 80 bit IEEE 754 extended precision number.
+func extended(b []byte) float64 {
+	exp := int(binary.BigEndian.Uint16(b[0:]) & 0x7FFF)
+	mant := binary.BigEndian.Uint64(b[2:])
+	f := math.Ldexp(float64(mant), exp-16383-63)
+	if b[0]&0x80 != 0 {
+		return -f
+	}
+	return f
+}
@@ -0,0 +1,73 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package audio
+
+type (
+	// Mixer sums the samples of several sound devices. The devices render
+	// their samples in emulated time, each one into its own Input. The sum
+	// is passed to the sink, as soon as all inputs have delivered.
+	Mixer struct {
+		out    Sink
+		inputs []*Input
+		sum    []int32
+		buf    []int16
+	}
+
+	// Input is a channel of the Mixer, receiving the samples of a device.
+	Input struct {
+		mixer *Mixer
+		pend  []int16
+	}
+)
+
+// mixLimit is the number of samples an input may get ahead of the
+// others. Beyond, the missing samples are taken as silence.
+const mixLimit = 0x4000
+
+// NewMixer creates a mixer passing the sum to the sink, which may be nil.
+func NewMixer(out Sink) *Mixer {
+	return &Mixer{out: out}
+}
+
+// Input adds an input channel.
+func (m *Mixer) Input() *Input {
+	in := &Input{mixer: m}
+	m.inputs = append(m.inputs, in)
+	return in
+}
+
+// Play queues the samples of the device and mixes, what is complete.
+func (in *Input) Play(samples []int16) {
+	in.pend = append(in.pend, samples...)
+	in.mixer.mix()
+}
+
+func (m *Mixer) mix() {
+	n, longest := len(m.inputs[0].pend), 0
+	for _, in := range m.inputs {
+		n, longest = min(n, len(in.pend)), max(longest, len(in.pend))
+	}
+	if longest > mixLimit {
+		n = longest
+	}
+	if n == 0 {
+		return
+	}
+
+	m.sum = append(m.sum[:0], make([]int32, n)...)
+	for _, in := range m.inputs {
+		k := min(n, len(in.pend))
+		for i, s := range in.pend[:k] {
+			m.sum[i] += int32(s)
+		}
+		in.pend = append(in.pend[:0], in.pend[k:]...)
+	}
+
+	m.buf = m.buf[:0]
+	for _, s := range m.sum {
+		m.buf = append(m.buf, int16(max(-0x8000, min(0x7FFF, s))))
+	}
+	if m.out != nil {
+		m.out.Play(m.buf)
+	}
+}
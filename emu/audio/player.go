@@ -0,0 +1,114 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+type (
+	// Player plays samples in real time. It pipes them into the playback
+	// client of the sound server on Linux desktops, pacat (PulseAudio,
+	// PipeWire) or aplay (ALSA). The device sets the pace.
+	Player struct {
+		*Buffer
+		cmd  *exec.Cmd
+		pipe io.WriteCloser
+		rate int
+		done chan struct{}
+	}
+)
+
+// ErrNoPlayer is returned, when no playback client is available.
+var ErrNoPlayer = errors.New("no audio playback client found (pacat, aplay)")
+
+// players are the command lines of the supported playback clients,
+// for raw stereo 16 bit little endian samples at the rate.
+var players = map[string]func(rate int) []string{
+	"pacat": func(rate int) []string {
+		return []string{"--playback", "--raw", "--format=s16le", "--channels=2",
+			"--rate=" + strconv.Itoa(rate), "--latency-msec=20", "--client-name=retro"}
+	},
+	"aplay": func(rate int) []string {
+		return []string{"-q", "-t", "raw", "-f", "S16_LE", "-c", "2",
+			"-r", strconv.Itoa(rate), "-B", "40000"}
+	},
+}
+
+// NewPlayer starts a playback client by name, "auto" picks the first
+// one available. The latency is the number of frames kept in stock.
+func NewPlayer(name string, rate int, latency int) (*Player, error) {
+	names := []string{name}
+	if name == "auto" {
+		names = []string{"pacat", "aplay"}
+	}
+
+	for _, name := range names {
+		args, ok := players[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown audio output %q", name)
+		}
+		path, err := exec.LookPath(name)
+		if err != nil {
+			continue
+		}
+		return startPlayer(exec.Command(path, args(rate)...), rate, latency)
+	}
+	return nil, ErrNoPlayer
+}
+
+func startPlayer(cmd *exec.Cmd, rate int, latency int) (*Player, error) {
+	pipe, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = os.Stderr
+
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	p := &Player{
+		Buffer: NewBuffer(latency),
+		cmd:    cmd,
+		pipe:   pipe,
+		rate:   rate,
+		done:   make(chan struct{}),
+	}
+	go p.feed()
+	return p, nil
+}
+
+// feed writes chunks of 10 ms to the client, blocking while its buffer
+// is full. It stops, when the pipe is closed.
+func (p *Player) feed() {
+	defer close(p.done)
+
+	samples := make([]int16, p.rate/100<<1)
+	buf := make([]byte, len(samples)<<1)
+
+	for {
+		p.Read(samples)
+		for i, s := range samples {
+			binary.LittleEndian.PutUint16(buf[i<<1:], uint16(s))
+		}
+		if _, err := p.pipe.Write(buf); err != nil {
+			return
+		}
+	}
+}
+
+// Close stops the playback client.
+func (p *Player) Close() error {
+	err := p.pipe.Close()
+	<-p.done
+	if err = errors.Join(err, p.cmd.Wait()); err != nil {
+		return fmt.Errorf("audio output: %w", err)
+	}
+	return nil
+}
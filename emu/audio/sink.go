@@ -0,0 +1,41 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package audio
+
+import (
+	"errors"
+)
+
+type (
+	// Sink receives 16 bit PCM samples, stereo interleaved (left, right).
+	// Play is called from the CPU thread and must not block, Close
+	// releases the output.
+	Sink interface {
+		Play(samples []int16)
+		Close() error
+	}
+
+	// multi passes the samples to several sinks.
+	multi []Sink
+)
+
+// Join combines sinks into one. Without sinks, the samples are discarded.
+func Join(sinks ...Sink) Sink {
+	return multi(sinks)
+}
+
+// Play passes the samples to all sinks.
+func (m multi) Play(samples []int16) {
+	for _, s := range m {
+		s.Play(samples)
+	}
+}
+
+// Close closes all sinks.
+func (m multi) Close() error {
+	var err error
+	for _, s := range m {
+		err = errors.Join(err, s.Close())
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"os"
+	"sync"
+)
+
+type (
+	// WAV writes the samples to a RIFF WAVE file. The samples are
+	// written as produced in emulated time, no sound hardware required.
+	WAV struct {
+		file *os.File
+		out  *bufio.Writer
+		size uint32 // bytes of sample data written
+		err  error
+		mu   sync.Mutex
+	}
+)
+
+const wavHeaderSize = 44
+
+// errClosed is kept, after the file has been closed.
+var errClosed = errors.New("file already closed")
+
+// NewWAV creates the file and writes the header for stereo 16 bit samples
+// at the rate. The sizes in the header are completed by Close.
+func NewWAV(path string, rate int) (*WAV, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	h := make([]byte, wavHeaderSize)
+	copy(h[0:], "RIFF")
+	copy(h[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16)           // fmt chunk size
+	binary.LittleEndian.PutUint16(h[20:], 1)            // PCM
+	binary.LittleEndian.PutUint16(h[22:], 2)            // stereo
+	binary.LittleEndian.PutUint32(h[24:], uint32(rate)) // sample rate
+	binary.LittleEndian.PutUint32(h[28:], uint32(rate)<<2)
+	binary.LittleEndian.PutUint16(h[32:], 4)  // block align
+	binary.LittleEndian.PutUint16(h[34:], 16) // bits per sample
+	copy(h[36:], "data")
+
+	w := &WAV{file: file, out: bufio.NewWriter(file)}
+	if _, err = w.out.Write(h); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Play appends the samples to the file. The first error is
+// kept and returned by Close, further samples are discarded.
+func (w *WAV) Play(samples []int16) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return
+	}
+	// The data chunk size is limited to 4GB.
+	if w.size+uint32(len(samples)<<1) < w.size {
+		return
+	}
+	w.err = binary.Write(w.out, binary.LittleEndian, samples)
+	w.size += uint32(len(samples) << 1)
+}
+
+// Close completes the header and closes the file.
+func (w *WAV) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err == errClosed {
+		return nil
+	}
+	err := errors.Join(w.err, w.out.Flush())
+
+	sizes := make([]byte, 4)
+	binary.LittleEndian.PutUint32(sizes, wavHeaderSize-8+w.size)
+	_, e1 := w.file.WriteAt(sizes, 4)
+	binary.LittleEndian.PutUint32(sizes, w.size)
+	_, e2 := w.file.WriteAt(sizes, wavHeaderSize-4)
+
+	err = errors.Join(err, e1, e2, w.file.Close())
+	w.err = errClosed
+	return err
+}
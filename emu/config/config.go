@@ -13,11 +13,14 @@ import (
 type (
 	// Config is the main configuration structure.
 	Config struct {
-		Version string
-		Window  `yaml:"window"`
-		CPU     `yaml:"cpu"`
-		Disk    `yaml:"disk"`
-		Render  `yaml:"render"`
+		Version  string
+		Window   `yaml:"window"`
+		CPU      `yaml:"cpu"`
+		Disk     `yaml:"disk"`
+		DiskSets `yaml:"disk-sets"`
+		Audio    `yaml:"audio"`
+		Tape     `yaml:"tape"`
+		Render   `yaml:"render"`
 	}
 
 	// Window ...
@@ -33,8 +36,31 @@ type (
 
 	// Disk ...
 	Disk struct {
-		Drive1 string `yaml:"drive-1"`
-		Drive2 string `yaml:"drive-2"`
+		Drive1        string `yaml:"drive-1"`
+		Drive2        string `yaml:"drive-2"`
+		Drive1Protect bool   `yaml:"drive-1-protect"`
+		Drive2Protect bool   `yaml:"drive-2-protect"`
+		Order         string `yaml:"order"`
+		Autosave      int    `yaml:"autosave"`
+		ROM           string `yaml:"rom"`
+		Set           string `yaml:"set"`
+	}
+
+	// DiskSets ...
+	DiskSets map[string][]string
+
+	// Audio ...
+	Audio struct {
+		Output       string `yaml:"output"`
+		Latency      int    `yaml:"latency"`
+		WAV          string `yaml:"wav"`
+		Mockingboard int    `yaml:"mockingboard"`
+	}
+
+	// Tape ...
+	Tape struct {
+		Play   string `yaml:"play"`
+		Record string `yaml:"record"`
 	}
 
 	// Render ...
@@ -76,9 +102,40 @@ var DefaultConfig = &Config{
 	},
 
 	// File paths of "inserted" Disk 1 and Disk 2 images.
-	// Paths can be HTTP URLs. Fetched images are not saved.
+	// Paths can be HTTP URLs. Fetched images are not saved, but
+	// cached in ~/.cache/retro. Images in .zip and .gz archives
+	// are unpacked, archived images are not saved either.
 	// Using the -1 and -2 options overrides this setting.
-	Disk: Disk{},
+	// Drive1Protect and Drive2Protect write-protect the drives.
+	// The sector order ("dos", "prodos") is detected by file
+	// name extension and content, unless Order is "dos" or "prodos".
+	// Modified images are written back on exit, and every
+	// Autosave seconds additionally, when greater than zero.
+	// ROM is the path to an alternative P5 boot ROM of the
+	// Disk II card, e.g. the 13 sector ROM for DOS 3.2 disks.
+	// Set selects one of the DiskSets, its first image goes
+	// into drive 1, the -set option overrides this setting.
+	Disk: Disk{Order: "auto"},
+
+	// Named lists of images, e.g. the sides of multi-disk software.
+	// The images of the selected set are inserted one after another.
+	DiskSets: DiskSets{},
+
+	// Output is the playback client for the speaker sound: "auto",
+	// "pacat", "aplay" or "none". Latency in milliseconds is the
+	// amount of sound buffered ahead. WAV is the path of a file,
+	// the sound is recorded to, the -wav option overrides it.
+	// Mockingboard is the slot of the Mockingboard sound card,
+	// usually 4. The default 0 leaves the card out.
+	Audio: Audio{Output: "auto", Latency: 50, Mockingboard: 0},
+
+	// Play is the path of the tape in the cassette player, a WAV
+	// or AIFF recording, an Applesoft program (.bas) or raw data,
+	// encoded as tape, read by the Monitor's R and Applesoft's
+	// LOAD. Record is the path of a WAV file, the cassette output
+	// is recorded to (W, SAVE).
+	// The -tape and -tape-rec options override these settings.
+	Tape: Tape{},
 
 	Render: Render{
 		Mono: Mono{
@@ -0,0 +1,116 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package builtin
+
+import (
+	"retro/emu/memory"
+)
+
+type (
+	// Cassette handles the cassette port. Each access to 0xC020 toggles
+	// the output level, which is recorded to the sink. Bit 7 of 0xC060
+	// is the input level of the tape played. The tape runs, while it is
+	// read, and stops, when the machine does not read it for a while.
+	// A tape played to its end is rewound, when it is read again.
+	Cassette struct {
+		clock memory.Clock
+		rec   *wave
+		edges []uint64 // CPU cycles of the level changes
+		size  uint64   // CPU cycles of the tape
+		pos   uint64   // tape position in CPU cycles
+		next  int      // index of the next edge
+		last  uint64   // CPU cycle of the last read
+	}
+)
+
+const (
+	// tapeHz is the clock of the tape timing. The Monitor routines count
+	// the cycles of an Apple II at 1.02 MHz, independent of the clock
+	// setting, so are the tapes.
+	tapeHz = 1_020_484
+
+	// tapeStop is the number of CPU cycles without reads, the tape stops
+	// after. The Monitor waits some seconds into the header, before it
+	// reads the tape, meanwhile the tape must keep running.
+	tapeStop = 5 * tapeHz
+)
+
+// NewCassette creates a cassette port without a tape and no recording.
+func NewCassette(clock memory.Clock) *Cassette {
+	return &Cassette{clock: clock}
+}
+
+// Insert inserts the tape to play, at its beginning.
+func (c *Cassette) Insert(tape *Tape) {
+	c.edges = make([]uint64, len(tape.edges))
+	for i, t := range tape.edges {
+		c.edges[i] = uint64(t * tapeHz)
+	}
+	c.size = uint64(tape.length * tapeHz)
+	c.pos, c.next = 0, 0
+}
+
+// Record records the output to the sink, rate samples per second.
+func (c *Cassette) Record(sink Sink, rate int) {
+	c.rec = newWave(c.clock, tapeHz, rate, sink)
+}
+
+// Read reads a byte, if this device is sensitive to this address.
+func (c *Cassette) Read(lo, hi byte) (byte, bool) {
+	switch {
+	case hi == 0xC0 && lo&0xF0 == 0x20: // TAPEOUT
+		c.toggle()
+		return 0, true
+	case hi == 0xC0 && (lo == 0x60 || lo == 0x68): // TAPEIN
+		return c.level(), true
+	}
+	return 0, false
+}
+
+// Write writes a byte, if this device is sensitive to this address.
+func (c *Cassette) Write(lo, hi, _ byte) bool {
+	if hi == 0xC0 && lo&0xF0 == 0x20 { // TAPEOUT
+		c.toggle()
+		return true
+	}
+	return false
+}
+
+// Reset does nothing here.
+func (*Cassette) Reset() {}
+
+// Slot is set by the memory Manager, depending on where this device was mounted.
+func (*Cassette) Slot(byte) {}
+
+// Flush passes the recorded samples to the sink.
+func (c *Cassette) Flush() {
+	if c.rec != nil {
+		c.rec.flush()
+	}
+}
+
+func (c *Cassette) toggle() {
+	if c.rec != nil {
+		c.rec.toggle()
+	}
+}
+
+// level runs the tape up to now and returns its level in bit 7.
+func (c *Cassette) level() byte {
+	now := c.clock.Cycles()
+	d := now - c.last
+	c.last = now
+
+	switch {
+	case d <= tapeStop:
+		c.pos += d
+	case c.pos >= c.size:
+		c.pos, c.next = 0, 0
+	}
+
+	// An odd number of level changes so far, the level is high.
+	for c.next < len(c.edges) && c.edges[c.next] <= c.pos {
+		c.next++
+	}
+	return byte(c.next&0x01) << 7
+}
@@ -4,36 +4,75 @@ package builtin
 
 import (
 	"retro/emu/memory"
+	"sync/atomic"
 )
 
 type (
-	// Paddles handles an analog paddle devices.
+	// Paddles handles an analog paddle devices. Accessing 0xC070 starts
+	// the four timers of the 558 chip, bit 7 of 0xC064-0xC067 is set,
+	// until the timer of the paddle has run out. The duration scales
+	// with the position of the paddle and is measured in CPU cycles.
 	Paddles struct {
-		mem memory.Memory
+		mem     memory.Memory
+		clock   memory.Clock
+		pos     [4]atomic.Uint32 // paddle positions 0-255
+		trigger uint64           // CPU cycle of the last timer start
 	}
 )
 
+const (
+	// paddleStep is the number of CPU cycles per paddle position, and
+	// paddleDelay the cycles from the timer start to the first reading
+	// of the Monitor's PREAD loop, which counts the positions.
+	paddleStep  = 11
+	paddleDelay = 8
+)
+
 // NewPaddle creates a new analog paddle device driver.
-func NewPaddle(mem memory.Memory) memory.Device {
-	return &Paddles{mem: mem}
+func NewPaddle(mem memory.Memory, clock memory.Clock) *Paddles {
+	return &Paddles{mem: mem, clock: clock}
+}
+
+// Move sets the position (0-255) of the paddle num (0-3). It may be
+// called concurrently to the CPU.
+func (p *Paddles) Move(num int, pos byte) {
+	p.pos[num&0x03].Store(uint32(pos))
 }
 
 // Read reads a byte, if this device is sensitive to this address.
 func (p *Paddles) Read(lo, hi byte) (byte, bool) {
-	if hi == 0xC0 && lo >= 0x61 && lo <= 0x63 { // BUTN0, BUTN1, BUTN2
+	if hi != 0xC0 {
+		return 0, false
+	}
+	switch {
+	case lo >= 0x61 && lo <= 0x63: // BUTN0, BUTN1, BUTN2
 		b := p.mem.DMA()[0xC000|int(lo)]
 		p.mem.DMA()[0xC000|int(lo)] = 0x00
 		return b, true
-	}
 
+	case lo >= 0x64 && lo <= 0x67: // PADDL0, PADDL1, PADDL2, PADDL3
+		return p.timer(lo & 0x03), true
+
+	case lo == 0x70: // PTRIG
+		p.trigger = p.clock.Cycles()
+		return 0, true
+	}
 	return 0, false
 }
 
 // Write writes a byte, if this device is sensitive to this address.
 func (p *Paddles) Write(lo, hi, b byte) bool {
-	if hi == 0xC0 && lo >= 0x61 && lo <= 0x63 { // BUTN0, BUTN1, BUTN2
+	if hi != 0xC0 {
+		return false
+	}
+	switch {
+	case lo >= 0x61 && lo <= 0x63: // BUTN0, BUTN1, BUTN2
 		p.mem.DMA()[0xC000|int(lo)] = b
 		return true
+
+	case lo == 0x70: // PTRIG
+		p.trigger = p.clock.Cycles()
+		return true
 	}
 	return false
 }
@@ -43,3 +82,12 @@ func (*Paddles) Reset() {}
 
 // Slot is set by the memory Manager, depending on where this device was mounted.
 func (*Paddles) Slot(byte) {}
+
+// timer returns bit 7 set, while the timer of the paddle num runs.
+func (p *Paddles) timer(num byte) byte {
+	d := paddleDelay + paddleStep*uint64(p.pos[num].Load())
+	if p.clock.Cycles()-p.trigger < d {
+		return 0x80
+	}
+	return 0x00
+}
@@ -0,0 +1,62 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package builtin
+
+import (
+	"retro/emu/memory"
+)
+
+type (
+	// Sink receives 16 bit PCM samples, stereo interleaved (left, right),
+	// e.g. an audio output. Play is called from the CPU thread and must
+	// not block.
+	Sink interface {
+		Play(samples []int16)
+	}
+
+	// Speaker handles the I/O soft switch 0xC030. Each access toggles
+	// the speaker cone between two positions. The toggles are stamped
+	// with the CPU cycle and converted into PCM samples for the sink.
+	Speaker struct {
+		wave *wave
+	}
+)
+
+// NewSpeaker creates a speaker device. The CPU runs at hz cycles per
+// second, the sink receives rate samples per second. Without a sink,
+// the speaker is silent.
+func NewSpeaker(clock memory.Clock, hz int, rate int, sink Sink) *Speaker {
+	return &Speaker{wave: newWave(clock, hz, rate, sink)}
+}
+
+// Read reads a byte, if this device is sensitive to this address.
+func (s *Speaker) Read(lo, hi byte) (byte, bool) {
+	if hi == 0xC0 && lo == 0x30 { // SPKR
+		s.wave.toggle()
+		return 0, true
+	}
+	return 0, false
+}
+
+// Write writes a byte, if this device is sensitive to this address.
+func (s *Speaker) Write(lo, hi, _ byte) bool {
+	if hi == 0xC0 && lo == 0x30 { // SPKR
+		s.wave.toggle()
+		return true
+	}
+	return false
+}
+
+// Reset does nothing here.
+func (*Speaker) Reset() {}
+
+// Slot is set by the memory Manager, depending on where this device was mounted.
+func (*Speaker) Slot(byte) {}
+
+// Flush renders the samples up to the current CPU cycle and passes
+// them to the sink. It keeps the sample stream going, when the speaker
+// is not accessed, and is called by the machine after each batch of
+// CPU steps.
+func (s *Speaker) Flush() {
+	s.wave.flush()
+}
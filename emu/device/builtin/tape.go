@@ -0,0 +1,124 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package builtin
+
+type (
+	// Tape is a cassette recording, reduced to the points in time (in
+	// seconds), where the level changes. The level starts low.
+	Tape struct {
+		edges  []float64
+		length float64
+	}
+)
+
+// The Apple II cassette format, half-cycle durations in seconds. Each
+// record starts with a 770 Hz header tone and a short sync cycle. A bit
+// is one full cycle, 2 kHz for a zero, 1 kHz for a one. The bytes are
+// written most significant bit first, followed by a checksum byte.
+const (
+	tapeHeader  = 650e-6
+	tapeSync1   = 200e-6
+	tapeSync2   = 250e-6
+	tapeZero    = 250e-6
+	tapeOne     = 500e-6
+	tapeLeader  = 4000 // header cycles, 5.2 seconds, the Monitor skips 3.7
+	tapeTrailer = 150  // header cycles after the checksum
+)
+
+// NewTape creates a tape from a recording (samples at rate per second).
+// The level changes, when the signal crosses its mean value, with some
+// hysteresis against noise. The crossing time is interpolated.
+func NewTape(samples []int16, rate int) *Tape {
+	t := &Tape{length: float64(len(samples)) / float64(rate)}
+	if len(samples) == 0 {
+		return t
+	}
+
+	mean, peak := 0.0, 0.0
+	for _, s := range samples {
+		mean += float64(s)
+	}
+	mean /= float64(len(samples))
+	for _, s := range samples {
+		peak = max(peak, abs(float64(s)-mean))
+	}
+	hyst := peak / 20
+
+	high := float64(samples[0])-mean > hyst
+	if high {
+		t.edges = append(t.edges, 0)
+	}
+	for i := 1; i < len(samples); i++ {
+		s0, s1 := float64(samples[i-1])-mean, float64(samples[i])-mean
+
+		// The level changes, where the signal crosses the threshold.
+		th := hyst
+		switch {
+		case high && s1 < -hyst:
+			th = -hyst
+		case !high && s1 > hyst:
+		default:
+			continue
+		}
+		frac := 1.0
+		if s1 != s0 {
+			frac = max(0, min(1, (th-s0)/(s1-s0)))
+		}
+		t.edges = append(t.edges, (float64(i-1)+frac)/float64(rate))
+		high = !high
+	}
+	return t
+}
+
+// EncodeTape creates a tape with records in the Apple II cassette format,
+// as written by the Monitor's W command and read by its R command.
+func EncodeTape(records ...[]byte) *Tape {
+	t := &Tape{}
+
+	for _, rec := range records {
+		for n := 0; n < tapeLeader; n++ {
+			t.cycle(tapeHeader, tapeHeader)
+		}
+		t.cycle(tapeSync1, tapeSync2)
+
+		sum := byte(0xFF)
+		for _, b := range rec {
+			sum ^= b
+			t.byte(b)
+		}
+		t.byte(sum)
+
+		for n := 0; n < tapeTrailer; n++ {
+			t.cycle(tapeHeader, tapeHeader)
+		}
+	}
+	return t
+}
+
+// Length returns the length of the tape in seconds.
+func (t *Tape) Length() float64 {
+	return t.length
+}
+
+func (t *Tape) byte(b byte) {
+	for i := 7; i >= 0; i-- {
+		if b>>i&0x01 == 0x01 {
+			t.cycle(tapeOne, tapeOne)
+		} else {
+			t.cycle(tapeZero, tapeZero)
+		}
+	}
+}
+
+// cycle appends a full cycle, high and low.
+func (t *Tape) cycle(high, low float64) {
+	t.edges = append(t.edges, t.length, t.length+high)
+	t.length += high + low
+}
+
+func abs(f float64) float64 {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
@@ -0,0 +1,110 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package builtin
+
+import (
+	"math"
+	"retro/emu/memory"
+)
+
+type (
+	// wave renders a square wave, toggled at CPU cycles, into PCM samples
+	// for the sink. The speaker and the cassette output are such waves.
+	wave struct {
+		clock memory.Clock
+		sink  Sink
+		level float64 // -1 or +1
+		step  float64 // CPU cycles per sample
+		at    float64 // CPU cycle, the waveform has been integrated to
+		next  float64 // CPU cycle of the next sample boundary
+		acc   float64 // integrated level of the current sample
+		low   float64 // low-pass filter state
+		in    float64 // DC blocker input state
+		out   float64 // DC blocker output state
+		alpha float64 // low-pass filter coefficient
+		buf   []int16
+	}
+)
+
+const (
+	// waveCutoff is the corner frequency of the low-pass filter, the
+	// small speaker and the analog circuit do not reproduce much above.
+	waveCutoff = 8000
+
+	// waveBlock is the number of frames passed to the sink at once.
+	waveBlock = 0x200
+
+	// waveVolume scales the level to the 16 bit sample range.
+	waveVolume = 0x2000
+
+	// dcBlock is the pole of the DC blocker. Without toggles, the cone
+	// returns to its rest position, so does the signal.
+	dcBlock = 0.995
+)
+
+// newWave creates a wave for CPU cycles at hz per second, the sink
+// receives rate samples per second.
+func newWave(clock memory.Clock, hz int, rate int, sink Sink) *wave {
+	w := &wave{
+		clock: clock,
+		sink:  sink,
+		level: -1,
+		low:   -1, // at rest, no click at power on
+		in:    -1,
+		step:  float64(hz) / float64(rate),
+		alpha: 1 - math.Exp(-2*math.Pi*waveCutoff/float64(rate)),
+		buf:   make([]int16, 0, waveBlock<<1),
+	}
+	w.at = float64(clock.Cycles())
+	w.next = w.at + w.step
+	return w
+}
+
+// flush renders the samples up to the current CPU cycle and passes
+// them to the sink. It keeps the sample stream going without toggles.
+func (w *wave) flush() {
+	if w.sink == nil {
+		return
+	}
+	w.advance(float64(w.clock.Cycles()))
+	if len(w.buf) > 0 {
+		w.sink.Play(w.buf)
+		w.buf = w.buf[:0]
+	}
+}
+
+func (w *wave) toggle() {
+	if w.sink == nil {
+		return
+	}
+	w.advance(float64(w.clock.Cycles()))
+	w.level = -w.level
+}
+
+// advance integrates the square wave up to the CPU cycle. Each sample
+// is the average level over its interval (a box filter), which limits
+// the bandwidth without aliasing of toggles between sample boundaries.
+func (w *wave) advance(to float64) {
+	for to >= w.next {
+		w.acc += w.level * (w.next - w.at)
+		w.emit(w.acc / w.step)
+		w.acc, w.at = 0, w.next
+		w.next += w.step
+	}
+	w.acc += w.level * (to - w.at)
+	w.at = to
+}
+
+// emit filters a sample and buffers it for the sink, on both channels.
+func (w *wave) emit(v float64) {
+	w.low += w.alpha * (v - w.low)
+	w.out = w.low - w.in + dcBlock*w.out
+	w.in = w.low
+
+	sample := int16(max(-1, min(1, w.out)) * waveVolume)
+	w.buf = append(w.buf, sample, sample)
+	if len(w.buf) == cap(w.buf) {
+		w.sink.Play(w.buf)
+		w.buf = w.buf[:0]
+	}
+}
@@ -2,61 +2,182 @@
 
 package diskette
 
+import (
+	"retro/emu/memory"
+	"sync"
+)
+
 type (
 	// Card is an Apple Disk II Interface Card.
 	Card struct {
-		rom  []byte
-		drv1 *Drive
-		drv2 *Drive
-		hot  *Drive
-		slot byte
+		rom   []byte
+		drv1  *Drive
+		drv2  *Drive
+		hot   *Drive
+		slot  byte
+		clock memory.Clock
+		last  uint64 // cycles at the last sequencer step
+		frac  uint64 // remainder of a bit cell in 125 ns units
+		latch byte
+		hold  int  // bit cells the latch has been holding a nibble
+		pend  byte // bit picked up while holding a nibble
+		q6    bool // false = SHIFT / true = LOAD
+		q7    bool // false = READ / true = WRITE
+		motor bool // the motor line, shared by both drives
+		mu    sync.Mutex
 	}
 )
 
-// NewCard creates a new Apple Disk II card with two diskette drives.
-func NewCard(rom []byte) *Card {
-
-	patched := make([]byte, len(rom))
-	copy(patched, rom)
-
-	// Skip wait routine.
-	patched[0x4C] = 0xA9 // LDA
-	patched[0x4D] = 0x00 // #0
-	patched[0x4E] = 0xEA // NOP
+// A bit cell takes 4 µs (32 x 125 ns), a CPU cycle about 1 µs (8 x 125 ns).
+const (
+	bitTiming   = 32
+	cycleTiming = 8
+)
 
+// NewCard creates a new Apple Disk II card with two diskette drives.
+// The clock drives the disk rotation, one bit cell every 4 CPU cycles.
+func NewCard(rom []byte, clock memory.Clock) *Card {
 	drv1 := NewDrive()
 	drv2 := NewDrive()
 
 	card := &Card{
-		rom:  patched,
-		drv1: drv1,
-		drv2: drv2,
-		hot:  drv1,
+		rom:   rom,
+		drv1:  drv1,
+		drv2:  drv2,
+		hot:   drv1,
+		clock: clock,
 	}
 	card.Reset()
 
 	return card
 }
 
-func (c *Card) switches() map[byte]func(b byte) byte {
-	return map[byte]func(b byte) byte{
-		0x00: func(b byte) byte { c.hot.Phase(0>>1, false); return 0 }, // DRV_P0_OFF
-		0x01: func(b byte) byte { c.hot.Phase(1>>1, true); return 0 },  // DRV_P0_ON
-		0x02: func(b byte) byte { c.hot.Phase(2>>1, false); return 0 }, // DRV_P1_OFF
-		0x03: func(b byte) byte { c.hot.Phase(3>>1, true); return 0 },  // DRV_P1_ON
-		0x04: func(b byte) byte { c.hot.Phase(4>>1, false); return 0 }, // DRV_P2_OFF
-		0x05: func(b byte) byte { c.hot.Phase(5>>1, true); return 0 },  // DRV_P2_ON
-		0x06: func(b byte) byte { c.hot.Phase(6>>1, false); return 0 }, // DRV_P3_OFF
-		0x07: func(b byte) byte { c.hot.Phase(7>>1, true); return 0 },  // DRV_P3_ON
-		0x08: func(b byte) byte { c.hot.Motor(false); return 0 },       // DRV_OFF
-		0x09: func(b byte) byte { c.hot.Motor(true); return 0 },        // DRV_ON
-		0x0A: func(b byte) byte { c.hot = c.drv1; return 0 },           // DRV_SEL1
-		0x0B: func(b byte) byte { c.hot = c.drv2; return 0 },           // DRV_SEL2
-		0x0C: func(b byte) byte { return c.hot.TrackReader().Read() },  // DRV_SHIFT / Q6L
-		0x0D: func(b byte) byte { return 0 },                           // DRV_LOAD / Q6H
-		0x0E: func(b byte) byte { return 0 },                           // DRV_READ / Q7L
-		0x0F: func(b byte) byte { return 0 },                           // DRV_WRITE / Q7H
+// switches maps the I/O soft switches. The functions receive the value
+// on the data bus and whether the switch was accessed by a write.
+func (c *Card) switches() map[byte]func(b byte, w bool) byte {
+	return map[byte]func(b byte, w bool) byte{
+		0x00: func(b byte, w bool) byte { c.hot.Phase(0>>1, false); return 0 }, // DRV_P0_OFF
+		0x01: func(b byte, w bool) byte { c.hot.Phase(1>>1, true); return 0 },  // DRV_P0_ON
+		0x02: func(b byte, w bool) byte { c.hot.Phase(2>>1, false); return 0 }, // DRV_P1_OFF
+		0x03: func(b byte, w bool) byte { c.hot.Phase(3>>1, true); return 0 },  // DRV_P1_ON
+		0x04: func(b byte, w bool) byte { c.hot.Phase(4>>1, false); return 0 }, // DRV_P2_OFF
+		0x05: func(b byte, w bool) byte { c.hot.Phase(5>>1, true); return 0 },  // DRV_P2_ON
+		0x06: func(b byte, w bool) byte { c.hot.Phase(6>>1, false); return 0 }, // DRV_P3_OFF
+		0x07: func(b byte, w bool) byte { c.hot.Phase(7>>1, true); return 0 },  // DRV_P3_ON
+		0x08: func(b byte, w bool) byte { c.power(false); return 0 },           // DRV_OFF
+		0x09: func(b byte, w bool) byte { c.power(true); return 0 },            // DRV_ON
+		0x0A: func(b byte, w bool) byte { c.enable(c.drv1); return 0 },         // DRV_SEL1
+		0x0B: func(b byte, w bool) byte { c.enable(c.drv2); return 0 },         // DRV_SEL2
+		0x0C: func(b byte, w bool) byte { c.q6 = false; return c.latch },       // DRV_SHIFT / Q6L
+		0x0D: func(b byte, w bool) byte { c.q6 = true; return c.load(b, w) },   // DRV_LOAD / Q6H
+		0x0E: func(b byte, w bool) byte { c.q7 = false; return c.sense() },     // DRV_READ / Q7L
+		0x0F: func(b byte, w bool) byte { c.q7 = true; return c.load(b, w) },   // DRV_WRITE / Q7H
+	}
+}
+
+// power turns the motor line on/off, it drives the selected drive.
+func (c *Card) power(state bool) {
+	c.motor = state
+	c.hot.Motor(state)
+}
+
+// enable selects the drive. The motor line switches over from the
+// former drive, DOS turns the motor on before it selects the drive.
+func (c *Card) enable(drv *Drive) {
+	if drv == c.hot {
+		return
+	}
+	c.hot.Motor(false)
+	c.hot = drv
+	c.hot.Motor(c.motor)
+}
+
+// load puts the data bus value into the data latch, when in write (Q7H)
+// and load (Q6H) mode. The sequencer shifts the latch out to the track
+// bit by bit, the DOS RWTS loads a nibble every 32 cycles.
+func (c *Card) load(b byte, w bool) byte {
+	if w && c.q6 && c.q7 {
+		c.latch = b
+	}
+	return c.sense()
+}
+
+// sense shifts the write-protect status into bit 7 of the latch, when in
+// sense mode (Q6H, Q7L). The DOS RWTS checks it with LDA $C08D,X followed
+// by LDA $C08E,X before writing, and reports "WRITE PROTECTED".
+func (c *Card) sense() byte {
+	if c.q6 && !c.q7 {
+		c.latch = c.latch>>1 | c.hot.status()
+	}
+	return c.latch
+}
+
+// step runs the sequencer for the bit cells, that have passed under the
+// head since the last access. The disk does not turn, when the motor is off.
+func (c *Card) step() {
+	now := c.clock.Cycles()
+	units := (now-c.last)*cycleTiming + c.frac
+	c.last = now
+
+	if !c.motor {
+		c.frac = 0
+		return
+	}
+	if im := c.hot.image; im != nil {
+		im.mu.Lock()
+		defer im.mu.Unlock()
+	}
+
+	timing := c.hot.timing()
+	cells := units / timing
+	c.frac = units % timing
+
+	// Only the last revolution matters after a long time.
+	track := c.hot.track()
+	if n := uint64(track.count) + 0x10; cells > n {
+		track.skip(cells - n)
+		cells = n
+	}
+
+	for ; cells > 0; cells-- {
+		switch {
+		case c.q7 && c.hot.protect:
+			track.skip(1)
+			c.latch <<= 1
+		case c.q7:
+			track.setBit(c.latch >> 7)
+			c.latch <<= 1
+		case c.q6:
+			track.skip(1)
+			c.latch = c.latch>>1 | c.hot.status()
+		default:
+			c.shift(track.bit())
+		}
+	}
+}
+
+// shift moves a bit from the track into the latch. A complete nibble
+// (most significant bit set) is held for one more bit cell, so the CPU
+// has time to pick it up. The zero bits following a nibble are skipped,
+// the next one bit starts a new nibble. This is how self-sync works.
+func (c *Card) shift(bit byte) {
+	if c.latch&0x80 == 0 {
+		c.latch = c.latch<<1 | bit
+		return
+	}
+	if c.hold++; c.hold == 1 {
+		c.pend = bit
+		return
 	}
+	switch {
+	case c.pend == 1:
+		c.latch = 0x02 | bit
+	case bit == 1:
+		c.latch = 0x01
+	default:
+		return
+	}
+	c.hold, c.pend = 0, 0
 }
 
 // Read reads a byte, if this device is sensitive to this address.
@@ -66,19 +187,29 @@ func (c *Card) Read(lo, hi byte) (byte, bool) {
 	if c.slot == 0 || c.slot > 7 {
 		return 0, false
 	}
-	// Read Card ROM? 0xCn00-0xCnFF?
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Read Card ROM? 0xCn00-0xCnFF? Hidden without a disk in drive 1,
+	// so the Autostart ROM does not try to boot from an empty drive and
+	// hang, but enters Applesoft. The card stays mounted for disks
+	// inserted at runtime, formerly it was left out without a disk.
 	if hi == 0xC0|c.slot {
+		if c.drv1.image == nil {
+			return 0, false
+		}
 		return c.rom[lo], true
 	}
 	// I/O switches?
 	if hi == 0xC0 && lo >= 0x80|(c.slot<<4) && lo <= 0x8F|(c.slot<<4) {
-		return c.switches()[lo&0x0F](0), true
+		c.step()
+		return c.switches()[lo&0x0F](0, false), true
 	}
 	return 0, false
 }
 
 // Write writes a byte, if this device is sensitive to this address.
-func (c *Card) Write(lo, hi, _ byte) bool {
+func (c *Card) Write(lo, hi, b byte) bool {
 
 	// Not interested?
 	if c.slot == 0 || c.slot > 7 {
@@ -90,7 +221,11 @@ func (c *Card) Write(lo, hi, _ byte) bool {
 	}
 	// I/O switches?
 	if hi == 0xC0 && lo >= 0x80|(c.slot<<4) && lo <= 0x8F|(c.slot<<4) {
-		c.switches()[lo&0x0F](0)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.step()
+		c.switches()[lo&0x0F](b, true)
 		return true
 	}
 	return false
@@ -98,8 +233,12 @@ func (c *Card) Write(lo, hi, _ byte) bool {
 
 // Reset resets the Disk Card.
 func (c *Card) Reset() {
+	c.motor = false
 	c.drv1.Motor(false)
 	c.drv2.Motor(false)
+	c.q6 = false
+	c.q7 = false
+	c.last = c.clock.Cycles()
 }
 
 // Slot is set by the memory Manager, depending on where this device was mounted.
@@ -112,7 +251,54 @@ func (c *Card) DMA() []byte {
 	return c.rom
 }
 
-// Drive returns the drive by number (0/1).
+// Insert inserts the image into the drive by number (0/1), while
+// the machine is running. The former image is returned, if any.
+func (c *Card) Insert(num int, image *Image) *Image {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	drv := c.Drive(num)
+	prev := drv.Eject()
+	drv.Insert(image)
+	return prev
+}
+
+// Eject removes the image from the drive by number (0/1), while
+// the machine is running, and returns it, if any.
+func (c *Card) Eject(num int) *Image {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.Drive(num).Eject()
+}
+
+// Image returns the image in the drive by number (0/1), or nil.
+func (c *Card) Image(num int) *Image {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.Drive(num).Image()
+}
+
+// Protect sets the write-protect state of the drive by number (0/1),
+// while the machine is running.
+func (c *Card) Protect(num int, state bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Drive(num).Protect(state)
+}
+
+// Protected returns the write-protect state of the drive by number (0/1).
+func (c *Card) Protected(num int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.Drive(num).Protected()
+}
+
+// Drive returns the drive by number (0/1). The drive must not be
+// accessed, while the machine is running, see Insert, Eject, Protect.
 func (c *Card) Drive(num int) *Drive {
 	if num&0x01 == 0x00 {
 		return c.drv1
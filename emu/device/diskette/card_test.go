@@ -0,0 +1,53 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package diskette
+
+import (
+	"bytes"
+	"testing"
+)
+
+type clock struct{ cycles uint64 }
+
+func (c *clock) Cycles() uint64 { return c.cycles }
+
+func TestCardReadsDrive2(t *testing.T) {
+	clk := &clock{}
+	card := NewCard(make([]byte, 0x100), clk)
+	card.Slot(6)
+
+	data := bytes.Repeat([]byte("DRIVE 2 "), 0x20)
+	im := NewBlankImage(DOSOrder)
+	if err := im.WriteSector(0, 5, DOSOrder, data); err != nil {
+		t.Fatal(err)
+	}
+	card.Insert(1, im)
+
+	// Motor on, then select drive 2, as DOS and ProDOS do.
+	card.Read(0xE9, 0xC0)
+	card.Read(0xEB, 0xC0)
+
+	// Pick up the nibbles like the RWTS: wait for bit 7, then for its end.
+	stream := make([]byte, 0, 2*NibbleTrackSize)
+	held := false
+	for len(stream) < cap(stream) && clk.cycles < 1_000_000 {
+		clk.cycles += 4
+		b, _ := card.Read(0xEC, 0xC0)
+		switch {
+		case b&0x80 != 0 && !held:
+			stream = append(stream, b)
+			held = true
+		case b&0x80 == 0:
+			held = false
+		}
+	}
+
+	trk, err := NewDecoder(DOSOrder).Decode(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := DOSOrder[DOSOrder.physical(5)]
+	if !bytes.Equal(trk.sectors[s][:], data) {
+		t.Errorf("sector 5: got % X", trk.sectors[s][:8])
+	}
+}
@@ -2,17 +2,183 @@
 
 package diskette
 
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
 type (
 	// Decoder is track/sector decoder.
-	Decoder struct{}
+	Decoder struct {
+		order     SectorOrder
+		nibbles   [0x100]byte
+		nibbles53 [0x100]byte
+	}
+)
+
+var (
+	// ErrAddressField is returned when no valid address field was found.
+	ErrAddressField = errors.New("address field missing")
+
+	// ErrDataField is returned when no data field follows an address field.
+	ErrDataField = errors.New("data field missing")
+
+	// ErrChecksum is returned when a checksum of a field does not match.
+	ErrChecksum = errors.New("checksum mismatch")
+
+	// ErrNibble is returned when a field contains an invalid disk byte.
+	ErrNibble = errors.New("invalid disk byte")
 )
 
-// NewDecoder return a new Decoder.
-func NewDecoder() *Decoder {
-	return &Decoder{}
+// Maximum distance between the end of an address field and a data field.
+const dataFieldGap = 0x40
+
+// NewDecoder return a new Decoder. The order determines
+// the position of the decoded sectors in the track data.
+func NewDecoder(order SectorOrder) *Decoder {
+	d := &Decoder{order: order}
+
+	// Reverse lookup, 0xFF marks invalid disk bytes.
+	for i := range d.nibbles {
+		d.nibbles[i] = 0xFF
+		d.nibbles53[i] = 0xFF
+	}
+	for i, b := range sixAndTwo {
+		d.nibbles[b] = byte(i)
+	}
+	for i, b := range fiveAndThree {
+		d.nibbles53[b] = byte(i)
+	}
+	return d
+}
+
+// Decode translates a byte stream, as produced by the Encoder or written
+// by DOS, back into the pure track data. The stream is treated as endless,
+// so a sector crossing the end of the stream is decoded properly.
+func (d *Decoder) Decode(stream []byte) (*Track, error) {
+	sector := func(num byte) byte { return d.order[num] }
+	return d.decode(stream, addrPrologue, 0x10, d.sixAndTwo, sector)
+}
+
+// decode finds count sectors with address fields introduced by prologue,
+// decodes the data fields with the field function, and places the data
+// at the position in the track, that the sector function maps to.
+func (d *Decoder) decode(
+	stream []byte,
+	prologue []byte,
+	count byte,
+	field func([]byte) ([]byte, error),
+	sector func(num byte) byte,
+) (*Track, error) {
+
+	track := &Track{track: -1}
+
+	// Unroll the endless stream once, so fields may wrap around.
+	buf := append(append([]byte{}, stream...), stream...)
+	found := make([]bool, count)
+	missing := make([]error, count)
+
+	// Like the DOS RWTS, skip broken address fields and address fields
+	// without data field, e.g. left by an interrupted write.
+	for pos := 0; pos < len(stream); pos++ {
+		if !bytes.HasPrefix(buf[pos:], prologue) {
+			continue
+		}
+		_, trk, num, err := d.addressField(buf[pos+len(prologue):])
+		if err != nil || num >= count || found[num] {
+			continue
+		}
+		if track.track == -1 {
+			track.track = int(trk)
+		}
+
+		// Data field follows closely, before the next address field.
+		from := pos + len(prologue) + 8 + 2
+		till := min(from+dataFieldGap, len(buf))
+
+		at := bytes.Index(buf[from:till], dataPrologue)
+		if at < 0 || bytes.Contains(buf[from:from+at], prologue) {
+			missing[num] = ErrDataField
+			continue
+		}
+		from += at + len(dataPrologue)
+
+		data, err := field(buf[from:])
+		if err != nil {
+			return nil, fmt.Errorf("track %d, sector %d: %w", trk, num, err)
+		}
+
+		copy(track.sectors[sector(num)][:], data)
+		found[num] = true
+	}
+
+	for num, ok := range found {
+		if ok {
+			continue
+		}
+		if err := missing[num]; err != nil {
+			return nil, fmt.Errorf("track %d, sector %d: %w", track.track, num, err)
+		}
+		return nil, fmt.Errorf("track %d, sector %d: %w", track.track, num, ErrAddressField)
+	}
+	return track, nil
+}
+
+// addressField decodes volume, track and sector of an address field.
+func (d *Decoder) addressField(b []byte) (vol, trk, sec byte, err error) {
+	if len(b) < 8+2 {
+		return 0, 0, 0, ErrAddressField
+	}
+	vol = d.fourAndFour(b[0], b[1])
+	trk = d.fourAndFour(b[2], b[3])
+	sec = d.fourAndFour(b[4], b[5])
+
+	if d.fourAndFour(b[6], b[7]) != vol^trk^sec {
+		return vol, trk, sec, fmt.Errorf("address %w", ErrChecksum)
+	}
+	// DOS does not care about the last epilogue byte, neither do we.
+	if !bytes.HasPrefix(b[8:], addrEpilogue[:2]) {
+		return vol, trk, sec, ErrAddressField
+	}
+	return vol, trk, sec, nil
+}
+
+// sixAndTwo reverses the Encoder's 6-and-2 translation of a data field.
+func (d *Decoder) sixAndTwo(b []byte) ([]byte, error) {
+	if len(b) < 0x157+2 {
+		return nil, ErrDataField
+	}
+	buf := [0x157]byte{}
+	bit := []byte{0, 2, 1, 3}
+
+	// Map disk bytes back to six-bit values.
+	for i := 0; i < 0x157; i++ {
+		if buf[i] = d.nibbles[b[i]]; buf[i] == 0xFF {
+			return nil, fmt.Errorf("%w 0x%02X", ErrNibble, b[i])
+		}
+	}
+
+	// Undo the exclusive OR chain, the last value is the checksum.
+	for pos := 1; pos < 0x156; pos++ {
+		buf[pos] ^= buf[pos-1]
+	}
+	if buf[0x156] != buf[0x155] {
+		return nil, fmt.Errorf("data %w", ErrChecksum)
+	}
+	if !bytes.HasPrefix(b[0x157:], dataEpilogue[:2]) {
+		return nil, ErrDataField
+	}
+
+	// Recombine the upper six bits with the shuffled bottom two bits.
+	out := make([]byte, 0x100)
+	for i := 0; i < 0x100; i++ {
+		aux := buf[i%0x56] >> (i / 0x56 * 2)
+		out[i] = buf[i+0x56]<<2 | bit[aux&0x03]
+	}
+	return out, nil
 }
 
-// Decode is not implemented.
-func (*Decoder) Decode(byte) *Track {
-	panic("Decoder.Decode() not implemented")
+func (*Decoder) fourAndFour(a, b byte) byte {
+	return (a<<1 | 0x01) & b
 }
@@ -0,0 +1,213 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package diskette
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// DOS 3.2 disks have 13 sectors per track, the data fields are 5-and-3
+// encoded. Reading them requires the 13 sector P5 boot ROM (341-0009).
+
+const (
+	// Sectors13 is the number of sectors per track of a DOS 3.2 disk.
+	Sectors13 = 13
+
+	// Image13Size is the size of a 13 sector (.d13) image with 35 tracks.
+	Image13Size = 35 * Sectors13 * 0x100
+)
+
+var (
+	addrPrologue13 = []byte{0xD5, 0xAA, 0xB5}
+
+	// DOS 3.2 interleaves the sectors physically on the track.
+	skew13 = []byte{
+		0x00, 0x0A, 0x07, 0x04, 0x01, 0x0B, 0x08,
+		0x05, 0x02, 0x0C, 0x09, 0x06, 0x03,
+	}
+
+	fiveAndThree = []byte{
+		0xAB, 0xAD, 0xAE, 0xAF, 0xB5, 0xB6, 0xB7, 0xBA,
+		0xBB, 0xBD, 0xBE, 0xBF, 0xD6, 0xD7, 0xDA, 0xDB,
+		0xDD, 0xDE, 0xDF, 0xEA, 0xEB, 0xED, 0xEE, 0xEF,
+		0xF5, 0xF6, 0xF7, 0xFA, 0xFB, 0xFD, 0xFE, 0xFF,
+	}
+)
+
+// LoadD13 loads a 13 sector DOS 3.2 (.d13) image and prepares
+// track readers for use with Drive.
+func (im *Image) LoadD13(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(data) != Image13Size {
+		return fmt.Errorf("13 sector image size %d, expected %d", len(data), Image13Size)
+	}
+
+	im.count = 35
+	for t, n := 0, im.count<<1; t < n; t += 2 {
+		for s := 0; s < Sectors13; s++ {
+			pos := ((t>>1)*Sectors13 + s) << 8
+			copy(im.tracks[t].sectors[s][:], data[pos:pos+0x100])
+		}
+	}
+	im.encoder.SetVolume(im.vtocVolume(Sectors13))
+
+	for t, n := 0, im.count<<1; t < n; t++ {
+		if t&0x01 == 0 {
+			im.tracks[t].track = t >> 1
+		} else {
+			im.tracks[t].track = 0xFF - t
+		}
+		im.readers[t<<1] = NewNibbleReader(
+			im.encoder.Encode13(&im.tracks[t]),
+		)
+	}
+
+	im.quarterTracks()
+	im.format = FormatD13
+	return nil
+}
+
+// writeSectors13 decodes written tracks and writes the 13 sectors to w.
+func (im *Image) writeSectors13(w io.Writer) (int64, error) {
+	lost := im.decodeTracks(im.decoder.Decode13)
+
+	total := int64(0)
+	for t, n := 0, im.count<<1; t < n; t += 2 {
+		for s := 0; s < Sectors13; s++ {
+			num, err := w.Write(im.tracks[t].sectors[s][:])
+			if total += int64(num); err != nil {
+				return total, err
+			}
+		}
+	}
+	return total, lost
+}
+
+// Encode13 translates the pure track data into a 13 sector byte
+// stream, that is suitable for reading by the DOS 3.2 boot ROM.
+func (e *Encoder) Encode13(track *Track) []byte {
+	vol := e.volume
+	buf := bytes.Buffer{}
+
+	for _, num := range skew13 {
+		_, _ = buf.Write(addrPrologue13)
+		_, _ = buf.Write(e.fourAndFour(vol))
+		_, _ = buf.Write(e.fourAndFour(byte(track.track)))
+		_, _ = buf.Write(e.fourAndFour(num))
+		_, _ = buf.Write(e.fourAndFour(vol ^ byte(track.track) ^ num))
+		_, _ = buf.Write(addrEpilogue)
+		_, _ = buf.Write(gap2)
+		_, _ = buf.Write(dataPrologue)
+		_, _ = buf.Write(e.fiveAndThree(track.sectors[num][:]))
+		_, _ = buf.Write(dataEpilogue)
+		_, _ = buf.Write(gap3)
+	}
+	return e.gap1(buf.Bytes())
+}
+
+// Decode13 translates a 13 sector byte stream back into the pure track data.
+func (d *Decoder) Decode13(stream []byte) (*Track, error) {
+	sector := func(num byte) byte { return num }
+	return d.decode(stream, addrPrologue13, Sectors13, d.fiveAndThree, sector)
+}
+
+// The 5-and-3 layout follows CiderPress (Apache License 2.0). The top five
+// bits of each byte go to 256 values, the bottom three bits of each group
+// of five bytes are combined into three values of 51 five-bit values each.
+func (*Encoder) fiveAndThree(b []byte) []byte {
+	top := [0x100]byte{}
+	three := [0x9A]byte{}
+
+	for i, chunk := 0, 0x32; chunk >= 0; i, chunk = i+5, chunk-1 {
+		b0, b1, b2, b3, b4 := b[i], b[i+1], b[i+2], b[i+3], b[i+4]
+
+		top[chunk+0x00] = b0 >> 3
+		top[chunk+0x33] = b1 >> 3
+		top[chunk+0x66] = b2 >> 3
+		top[chunk+0x99] = b3 >> 3
+		top[chunk+0xCC] = b4 >> 3
+
+		three[chunk+0x00] = (b0&0x07)<<2 | (b3&0x04)>>1 | (b4&0x04)>>2
+		three[chunk+0x33] = (b1&0x07)<<2 | (b3&0x02)>>0 | (b4&0x02)>>1
+		three[chunk+0x66] = (b2&0x07)<<2 | (b3&0x01)<<1 | (b4&0x01)>>0
+	}
+	top[0xFF] = b[0xFF] >> 3
+	three[0x99] = b[0xFF] & 0x07
+
+	// Exclusive OR each value with the one before it, threes first
+	// and backwards, map five-bit values up to full bytes.
+	buf := make([]byte, 0, len(three)+len(top)+1)
+	sum := byte(0)
+
+	for i := len(three) - 1; i >= 0; i-- {
+		buf = append(buf, fiveAndThree[three[i]^sum])
+		sum = three[i]
+	}
+	for i := 0; i < len(top); i++ {
+		buf = append(buf, fiveAndThree[top[i]^sum])
+		sum = top[i]
+	}
+	return append(buf, fiveAndThree[sum])
+}
+
+// fiveAndThree reverses the Encoder's 5-and-3 translation of a data field.
+func (d *Decoder) fiveAndThree(b []byte) ([]byte, error) {
+	top := [0x100]byte{}
+	three := [0x9A]byte{}
+
+	if len(b) < len(three)+len(top)+1+2 {
+		return nil, ErrDataField
+	}
+
+	// Map disk bytes back to five-bit values, undo the exclusive OR chain.
+	sum, pos := byte(0), 0
+	value := func() (byte, error) {
+		v := d.nibbles53[b[pos]]
+		if v == 0xFF {
+			return 0, fmt.Errorf("%w 0x%02X", ErrNibble, b[pos])
+		}
+		pos++
+		sum ^= v
+		return sum, nil
+	}
+
+	var err error
+	for i := len(three) - 1; i >= 0; i-- {
+		if three[i], err = value(); err != nil {
+			return nil, err
+		}
+	}
+	for i := 0; i < len(top); i++ {
+		if top[i], err = value(); err != nil {
+			return nil, err
+		}
+	}
+	if v, err := value(); err != nil {
+		return nil, err
+	} else if v != 0 {
+		return nil, fmt.Errorf("data %w", ErrChecksum)
+	}
+	if !bytes.HasPrefix(b[pos:], dataEpilogue[:2]) {
+		return nil, ErrDataField
+	}
+
+	// Recombine the top five bits with the bottom three bits.
+	out := make([]byte, 0x100)
+	for i, chunk := 0, 0x32; chunk >= 0; i, chunk = i+5, chunk-1 {
+		t0, t1, t2 := three[chunk], three[chunk+0x33], three[chunk+0x66]
+
+		out[i+0] = top[chunk+0x00]<<3 | t0>>2&0x07
+		out[i+1] = top[chunk+0x33]<<3 | t1>>2&0x07
+		out[i+2] = top[chunk+0x66]<<3 | t2>>2&0x07
+		out[i+3] = top[chunk+0x99]<<3 | (t0&0x02)<<1 | (t1 & 0x02) | (t2&0x02)>>1
+		out[i+4] = top[chunk+0xCC]<<3 | (t0&0x01)<<2 | (t1&0x01)<<1 | (t2 & 0x01)
+	}
+	out[0xFF] = top[0xFF]<<3 | three[0x99]&0x07
+
+	return out, nil
+}
@@ -0,0 +1,155 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package dos33
+
+import (
+	"errors"
+	"fmt"
+	"retro/emu/device/diskette"
+)
+
+type (
+	// Disk is a DOS 3.3 file system on a 16 sector image.
+	Disk struct {
+		image *diskette.Image
+		vtoc  VTOC
+	}
+
+	// TS is the location of a sector.
+	TS struct {
+		Track  byte
+		Sector byte
+	}
+)
+
+var (
+	// ErrNoDOS is returned, when the image has no DOS 3.3 file system.
+	ErrNoDOS = errors.New("no DOS 3.3 file system")
+
+	// ErrBroken is returned for chains leaving the disk or looping.
+	ErrBroken = errors.New("broken sector chain")
+)
+
+// Open reads the VTOC of a DOS 3.3 file system from the image.
+func Open(image *diskette.Image) (*Disk, error) {
+	buf, err := image.ReadSector(VTOCTrack, 0, diskette.DOSOrder)
+	if err != nil {
+		return nil, err
+	}
+	d := &Disk{image: image}
+	copy(d.vtoc[:], buf)
+
+	if d.vtoc[0x27] != pairs || d.vtoc[0x34] != Tracks || d.vtoc[0x35] != Sectors {
+		return nil, ErrNoDOS
+	}
+	return d, nil
+}
+
+// VTOC returns the Volume Table Of Contents.
+func (d *Disk) VTOC() *VTOC {
+	return &d.vtoc
+}
+
+// Catalog returns the entries of the catalog. Deleted entries are skipped,
+// the catalog ends with the first entry, that has never been used.
+func (d *Disk) Catalog() ([]Entry, error) {
+	list := []Entry{}
+
+	err := d.walkCatalog(func(e Entry) bool {
+		if e.Track == 0x00 {
+			return false
+		}
+		if e.Track != 0xFF {
+			list = append(list, e)
+		}
+		return true
+	})
+	return list, err
+}
+
+// Find returns the catalog entry by name.
+func (d *Disk) Find(name string) (Entry, error) {
+	list, err := d.Catalog()
+	if err != nil {
+		return Entry{}, err
+	}
+	for _, e := range list {
+		if e.Name == name {
+			return e, nil
+		}
+	}
+	return Entry{}, fmt.Errorf("%s: file not found", name)
+}
+
+// TrackSectorList returns the locations of the data sectors of a file.
+// A zero location is a sector, that has never been written to (random
+// access text files). Trailing zero locations are not returned.
+func (d *Disk) TrackSectorList(e Entry) ([]TS, error) {
+	list := []TS{}
+	seen := map[TS]bool{}
+
+	for ts := (TS{e.Track, e.Sector}); ts.Track != 0; {
+		if seen[ts] || !d.valid(ts) {
+			return nil, fmt.Errorf("%s: %w", e.Name, ErrBroken)
+		}
+		seen[ts] = true
+
+		buf, err := d.read(ts)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0x0C; i < 0x0C+pairs<<1; i += 2 {
+			list = append(list, TS{buf[i], buf[i+1]})
+		}
+		ts = TS{buf[0x01], buf[0x02]}
+	}
+
+	for len(list) > 0 && list[len(list)-1].Track == 0 {
+		list = list[:len(list)-1]
+	}
+	for _, ts := range list {
+		if ts.Track != 0 && !d.valid(ts) {
+			return nil, fmt.Errorf("%s: %w", e.Name, ErrBroken)
+		}
+	}
+	return list, nil
+}
+
+// walkCatalog calls fn for each catalog entry, until fn returns false.
+func (d *Disk) walkCatalog(fn func(e Entry) bool) error {
+	seen := map[TS]bool{}
+
+	for ts := (TS{d.vtoc[0x01], d.vtoc[0x02]}); ts.Track != 0; {
+		if seen[ts] || !d.valid(ts) {
+			return fmt.Errorf("catalog: %w", ErrBroken)
+		}
+		seen[ts] = true
+
+		buf, err := d.read(ts)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < entries; i++ {
+			pos := entryFirst + i*entrySize
+			e := parseEntry(buf[pos : pos+entrySize])
+			e.catTrack, e.catSector, e.offset = ts.Track, ts.Sector, pos
+			if !fn(e) {
+				return nil
+			}
+		}
+		ts = TS{buf[0x01], buf[0x02]}
+	}
+	return nil
+}
+
+func (d *Disk) read(ts TS) ([]byte, error) {
+	return d.image.ReadSector(int(ts.Track), int(ts.Sector), diskette.DOSOrder)
+}
+
+func (d *Disk) write(ts TS, buf []byte) error {
+	return d.image.WriteSector(int(ts.Track), int(ts.Sector), diskette.DOSOrder, buf)
+}
+
+func (*Disk) valid(ts TS) bool {
+	return ts.Track < Tracks && ts.Sector < Sectors
+}
@@ -0,0 +1,107 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package dos33
+
+import (
+	"fmt"
+	"strings"
+)
+
+type (
+	// Entry is a file entry of the catalog.
+	Entry struct {
+		Name    string
+		Type    FileType
+		Locked  bool
+		Sectors int
+
+		// Location of the first track/sector list sector.
+		Track  byte
+		Sector byte
+
+		// Location of the entry in the catalog.
+		catTrack  byte
+		catSector byte
+		offset    int
+	}
+
+	// FileType is the DOS 3.3 file type.
+	FileType byte
+)
+
+// File types, shown as letters by CATALOG.
+const (
+	TypeText        FileType = 0x00 // T
+	TypeInteger     FileType = 0x01 // I
+	TypeApplesoft   FileType = 0x02 // A
+	TypeBinary      FileType = 0x04 // B
+	TypeS           FileType = 0x08 // S
+	TypeRelocatable FileType = 0x10 // R
+	TypeA           FileType = 0x20 // new A
+	TypeB           FileType = 0x40 // new B
+)
+
+const (
+	entrySize  = 0x23
+	entryFirst = 0x0B
+	entries    = 7
+	nameSize   = 30
+)
+
+// ParseType returns the FileType for its letter (T, I, A, B, S, R).
+func ParseType(letter string) (FileType, error) {
+	for _, t := range []FileType{
+		TypeText, TypeInteger, TypeApplesoft, TypeBinary, TypeS, TypeRelocatable,
+	} {
+		if strings.EqualFold(t.String(), letter) {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown file type %q", letter)
+}
+
+// String returns the letter of the file type, as shown by CATALOG.
+func (t FileType) String() string {
+	switch {
+	case t&0x40 != 0:
+		return "B"
+	case t&0x20 != 0:
+		return "A"
+	case t&0x10 != 0:
+		return "R"
+	case t&0x08 != 0:
+		return "S"
+	case t&0x04 != 0:
+		return "B"
+	case t&0x02 != 0:
+		return "A"
+	case t&0x01 != 0:
+		return "I"
+	}
+	return "T"
+}
+
+// String returns the catalog line of the entry, as shown by CATALOG.
+func (e Entry) String() string {
+	lock := " "
+	if e.Locked {
+		lock = "*"
+	}
+	return fmt.Sprintf("%s%s %03d %s", lock, e.Type, e.Sectors%1000, e.Name)
+}
+
+// parseEntry parses the catalog entry at the buffer start.
+func parseEntry(b []byte) Entry {
+	name := make([]byte, nameSize)
+	for i := range name {
+		name[i] = b[0x03+i] & 0x7F
+	}
+	return Entry{
+		Name:    strings.TrimRight(string(name), " "),
+		Type:    FileType(b[0x02] & 0x7F),
+		Locked:  b[0x02]&0x80 != 0,
+		Sectors: int(b[0x21]) | int(b[0x22])<<8,
+		Track:   b[0x00],
+		Sector:  b[0x01],
+	}
+}
@@ -0,0 +1,352 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package dos33
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type (
+	// File is the content of a file without the DOS header.
+	File struct {
+		Name   string
+		Type   FileType
+		Locked bool
+		Addr   uint16 // Load address of binary (B) files.
+		Data   []byte
+	}
+)
+
+var (
+	// ErrDiskFull is returned, when there are not enough free sectors.
+	ErrDiskFull = errors.New("disk full")
+
+	// ErrCatalogFull is returned, when there is no free catalog entry.
+	ErrCatalogFull = errors.New("catalog full")
+
+	// ErrLocked is returned, when a locked file would be replaced.
+	ErrLocked = errors.New("file locked")
+)
+
+// ReadFile returns the file by name. The header of binary (B), Applesoft
+// (A) and Integer BASIC (I) files is removed, text (T) files end with the
+// first zero byte. Other files are returned as stored.
+func (d *Disk) ReadFile(name string) (*File, error) {
+	e, err := d.Find(name)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := d.readSectors(e)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{Name: e.Name, Type: e.Type, Locked: e.Locked}
+	word := func(pos int) int {
+		if pos+1 >= len(raw) {
+			return 0
+		}
+		return int(raw[pos]) | int(raw[pos+1])<<8
+	}
+
+	switch e.Type.String() {
+	case "B":
+		f.Addr = uint16(word(0))
+		f.Data = raw[min(4, len(raw)):min(4+word(2), len(raw))]
+	case "A", "I":
+		f.Data = raw[min(2, len(raw)):min(2+word(0), len(raw))]
+	case "T":
+		if pos := strings.IndexByte(string(raw), 0x00); pos >= 0 {
+			raw = raw[:pos]
+		}
+		f.Data = raw
+	default:
+		f.Data = raw
+	}
+	return f, nil
+}
+
+// WriteFile stores the file, the DOS header is added according to the type.
+// An existing file of the same name is replaced, unless it is locked.
+func (d *Disk) WriteFile(f *File) error {
+	name, err := checkName(f.Name)
+	if err != nil {
+		return err
+	}
+
+	raw := []byte{}
+	switch f.Type.String() {
+	case "B":
+		raw = append(raw, byte(f.Addr), byte(f.Addr>>8))
+		fallthrough
+	case "A", "I":
+		raw = append(raw, byte(len(f.Data)), byte(len(f.Data)>>8))
+	}
+	raw = append(raw, f.Data...)
+
+	// Data sectors first, then the track/sector list sectors.
+	data := (len(raw) + 0xFF) >> 8
+	lists := max(1, (data+pairs-1)/pairs)
+
+	// The sectors and the catalog entry of a file to be replaced
+	// count as free. It is deleted, when the new file fits.
+	old, err := d.Find(name)
+	switch {
+	case err == nil && old.Locked:
+		return fmt.Errorf("%s: %w", name, ErrLocked)
+	case err == nil:
+		used, err := d.fileSectors(old)
+		if err != nil {
+			return err
+		}
+		if len(d.freeSectors(data+lists))+len(used) < data+lists {
+			return fmt.Errorf("%s: %w", name, ErrDiskFull)
+		}
+		if err = d.Delete(name); err != nil {
+			return err
+		}
+	default:
+		if len(d.freeSectors(data+lists)) < data+lists {
+			return fmt.Errorf("%s: %w", name, ErrDiskFull)
+		}
+	}
+
+	slot, err := d.freeEntry()
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	free := d.freeSectors(data + lists)
+
+	for i := 0; i < data; i++ {
+		buf := make([]byte, 0x100)
+		copy(buf, raw[i<<8:])
+		if err = d.write(free[i], buf); err != nil {
+			return err
+		}
+	}
+	for l := 0; l < lists; l++ {
+		buf := make([]byte, 0x100)
+		if l+1 < lists {
+			next := free[data+l+1]
+			buf[0x01], buf[0x02] = next.Track, next.Sector
+		}
+		buf[0x05], buf[0x06] = byte(l*pairs), byte(l*pairs>>8)
+
+		for i := 0; i < pairs && l*pairs+i < data; i++ {
+			ts := free[l*pairs+i]
+			buf[0x0C+i<<1], buf[0x0D+i<<1] = ts.Track, ts.Sector
+		}
+		if err = d.write(free[data+l], buf); err != nil {
+			return err
+		}
+	}
+
+	for _, ts := range free {
+		d.vtoc.Allocate(int(ts.Track), int(ts.Sector))
+	}
+	if err = d.write(TS{VTOCTrack, 0}, d.vtoc[:]); err != nil {
+		return err
+	}
+
+	kind := byte(f.Type)
+	if f.Locked {
+		kind |= 0x80
+	}
+	entry := make([]byte, entrySize)
+	entry[0x00], entry[0x01], entry[0x02] = free[data].Track, free[data].Sector, kind
+	for i := 0; i < nameSize; i++ {
+		entry[0x03+i] = 0xA0
+		if i < len(name) {
+			entry[0x03+i] = name[i] | 0x80
+		}
+	}
+	entry[0x21], entry[0x22] = byte(data+lists), byte((data+lists)>>8)
+
+	return d.updateEntry(slot, entry)
+}
+
+// Delete removes the file by name and frees its sectors, as DOS does: the
+// track of the track/sector list moves to the last character of the name.
+func (d *Disk) Delete(name string) error {
+	e, err := d.Find(name)
+	if err != nil {
+		return err
+	}
+	used, err := d.fileSectors(e)
+	if err != nil {
+		return err
+	}
+
+	for _, ts := range used {
+		d.vtoc.Free(int(ts.Track), int(ts.Sector))
+	}
+	if err = d.write(TS{VTOCTrack, 0}, d.vtoc[:]); err != nil {
+		return err
+	}
+
+	buf, err := d.read(TS{e.catTrack, e.catSector})
+	if err != nil {
+		return err
+	}
+	buf[e.offset+0x20] = buf[e.offset+0x00]
+	buf[e.offset+0x00] = 0xFF
+	return d.write(TS{e.catTrack, e.catSector}, buf)
+}
+
+// readSectors returns the concatenated data sectors of a file.
+func (d *Disk) readSectors(e Entry) ([]byte, error) {
+	list, err := d.TrackSectorList(e)
+	if err != nil {
+		return nil, err
+	}
+	raw := make([]byte, 0, len(list)<<8)
+	for _, ts := range list {
+		if ts.Track == 0 {
+			raw = append(raw, make([]byte, 0x100)...)
+			continue
+		}
+		buf, err := d.read(ts)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, buf...)
+	}
+	return raw, nil
+}
+
+// fileSectors returns the locations of the sectors used by a file: the
+// data sectors, without the holes of sparse files, and the lists.
+func (d *Disk) fileSectors(e Entry) ([]TS, error) {
+	list, err := d.TrackSectorList(e)
+	if err != nil {
+		return nil, err
+	}
+	lists, err := d.listSectors(e)
+	if err != nil {
+		return nil, err
+	}
+
+	used := []TS{}
+	for _, ts := range append(list, lists...) {
+		if ts.Track != 0 {
+			used = append(used, ts)
+		}
+	}
+	return used, nil
+}
+
+// listSectors returns the locations of the track/sector list sectors.
+func (d *Disk) listSectors(e Entry) ([]TS, error) {
+	list := []TS{}
+	for ts := (TS{e.Track, e.Sector}); ts.Track != 0 && len(list) < Tracks*Sectors; {
+		if !d.valid(ts) {
+			return nil, fmt.Errorf("%s: %w", e.Name, ErrBroken)
+		}
+		list = append(list, ts)
+		buf, err := d.read(ts)
+		if err != nil {
+			return nil, err
+		}
+		ts = TS{buf[0x01], buf[0x02]}
+	}
+	return list, nil
+}
+
+// freeSectors finds up to count free sectors. Like DOS, tracks next to
+// the catalog track are used first, sectors from the highest down.
+func (d *Disk) freeSectors(count int) []TS {
+	list := []TS{}
+	for i := 1; i < Tracks; i++ {
+		for _, t := range []int{VTOCTrack + i, VTOCTrack - i} {
+			if t <= 0 || t >= Tracks {
+				continue
+			}
+			for s := Sectors - 1; s >= 0 && len(list) < count; s-- {
+				if d.vtoc.IsFree(t, s) {
+					list = append(list, TS{byte(t), byte(s)})
+				}
+			}
+		}
+	}
+	return list
+}
+
+// freeEntry finds the first catalog entry, that is unused or deleted.
+func (d *Disk) freeEntry() (Entry, error) {
+	var slot *Entry
+	err := d.walkCatalog(func(e Entry) bool {
+		if e.Track == 0x00 || e.Track == 0xFF {
+			slot = &e
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return Entry{}, err
+	}
+	if slot == nil {
+		return Entry{}, ErrCatalogFull
+	}
+	return *slot, nil
+}
+
+// updateEntry replaces the catalog entry at the location of slot.
+func (d *Disk) updateEntry(slot Entry, entry []byte) error {
+	buf, err := d.read(TS{slot.catTrack, slot.catSector})
+	if err != nil {
+		return err
+	}
+	copy(buf[slot.offset:slot.offset+entrySize], entry)
+	return d.write(TS{slot.catTrack, slot.catSector}, buf)
+}
+
+// FromText converts host text into DOS text: the characters have the high
+// bit set, lines end with a carriage return instead of a line feed.
+func FromText(b []byte) []byte {
+	buf := make([]byte, 0, len(b))
+	for _, c := range b {
+		switch c {
+		case '\r':
+			continue
+		case '\n':
+			c = '\r'
+		}
+		buf = append(buf, c|0x80)
+	}
+	return buf
+}
+
+// ToText converts DOS text into host text, see FromText.
+func ToText(b []byte) []byte {
+	buf := make([]byte, 0, len(b))
+	for _, c := range b {
+		if c &= 0x7F; c == '\r' {
+			c = '\n'
+		}
+		buf = append(buf, c)
+	}
+	return buf
+}
+
+// checkName returns the upper case file name, when valid.
+func checkName(name string) (string, error) {
+	name = strings.ToUpper(name)
+	switch {
+	case name == "":
+		return "", errors.New("empty file name")
+	case len(name) > nameSize:
+		return "", fmt.Errorf("%s: file name longer than %d characters", name, nameSize)
+	case name[0] < 'A' || name[0] > 'Z':
+		return "", fmt.Errorf("%s: file name must start with a letter", name)
+	case strings.ContainsAny(name, ","):
+		return "", fmt.Errorf("%s: file name must not contain a comma", name)
+	}
+	for _, c := range []byte(name) {
+		if c < 0x20 || c > 0x7E {
+			return "", fmt.Errorf("%s: invalid character in file name", name)
+		}
+	}
+	return name, nil
+}
@@ -0,0 +1,62 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package dos33
+
+import (
+	"bytes"
+	"errors"
+	"retro/emu/device/diskette"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	image := diskette.NewBlankImage(diskette.DOSOrder)
+	if err := Format(image, 254); err != nil {
+		t.Fatal(err)
+	}
+	d, err := Open(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Binary files of n sectors, the header takes four bytes.
+	file := func(name string, n int, b byte) *File {
+		return &File{Name: name, Type: TypeBinary, Addr: 0x0800, Data: bytes.Repeat([]byte{b}, n<<8-4)}
+	}
+	check := func(f *File) {
+		got, err := d.ReadFile(f.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Data, f.Data) {
+			t.Errorf("%s: read %d bytes, differ from the %d written", f.Name, len(got.Data), len(f.Data))
+		}
+	}
+
+	// More than one track/sector list (122 sectors).
+	big := file("BIG", 200, 0x42)
+	if err = d.WriteFile(big); err != nil {
+		t.Fatal(err)
+	}
+	check(big)
+
+	// Leave about 40 sectors free, BIG takes 202 with its two lists.
+	free := len(d.freeSectors(Tracks * Sectors))
+	if err = d.WriteFile(file("FILL", free-44, 0x00)); err != nil {
+		t.Fatal(err)
+	}
+	free = len(d.freeSectors(Tracks * Sectors))
+
+	// A replacement, that does not fit, keeps the file.
+	if err = d.WriteFile(file("BIG", free+202-1, 0x43)); !errors.Is(err, ErrDiskFull) {
+		t.Fatalf("got %v, expected %v", err, ErrDiskFull)
+	}
+	check(big)
+
+	// A replacement fits into the sectors of the file and the free ones.
+	big = file("BIG", free+202-2, 0x44)
+	if err = d.WriteFile(big); err != nil {
+		t.Fatal(err)
+	}
+	check(big)
+}
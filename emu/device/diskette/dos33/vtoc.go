@@ -0,0 +1,102 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package dos33
+
+import (
+	"retro/emu/device/diskette"
+)
+
+type (
+	// VTOC is the Volume Table Of Contents, the sector of a DOS 3.3
+	// disk, that locates the catalog and keeps track of free sectors.
+	VTOC [0x100]byte
+)
+
+const (
+	// Tracks is the number of tracks of a DOS 3.3 disk.
+	Tracks = 35
+
+	// Sectors is the number of sectors per track.
+	Sectors = 16
+
+	// VTOCTrack is the track of the VTOC and the catalog.
+	VTOCTrack = 0x11
+
+	// DefaultVolume is the volume number, DOS uses by default.
+	DefaultVolume = 254
+
+	// pairs is the number of track/sector pairs per list sector.
+	pairs = 0x7A
+)
+
+// Format writes an empty DOS 3.3 file system to the image: the VTOC and
+// the catalog sectors on track 17. The disk contains no DOS image and is
+// not bootable, so only track 0 is reserved next to track 17.
+func Format(image *diskette.Image, volume byte) error {
+	vtoc := VTOC{}
+
+	vtoc[0x01] = VTOCTrack
+	vtoc[0x02] = Sectors - 1
+	vtoc[0x03] = 0x03
+	vtoc[0x06] = volume
+	vtoc[0x27] = pairs
+	vtoc[0x30] = VTOCTrack + 1
+	vtoc[0x31] = 0x01
+	vtoc[0x34] = Tracks
+	vtoc[0x35] = Sectors
+	vtoc[0x37] = 0x01
+
+	for t := 1; t < Tracks; t++ {
+		if t != VTOCTrack {
+			for s := 0; s < Sectors; s++ {
+				vtoc.Free(t, s)
+			}
+		}
+	}
+
+	// Catalog sectors are chained from sector 15 down to sector 1.
+	for s := Sectors - 1; s > 0; s-- {
+		cat := make([]byte, 0x100)
+		if s > 1 {
+			cat[0x01] = VTOCTrack
+			cat[0x02] = byte(s - 1)
+		}
+		if err := image.WriteSector(VTOCTrack, s, diskette.DOSOrder, cat); err != nil {
+			return err
+		}
+	}
+	return image.WriteSector(VTOCTrack, 0, diskette.DOSOrder, vtoc[:])
+}
+
+// Volume returns the volume number of the disk.
+func (v *VTOC) Volume() byte {
+	return v[0x06]
+}
+
+// IsFree signals, whether the sector is not in use.
+func (v *VTOC) IsFree(track, sector int) bool {
+	pos, mask := v.bitmap(track, sector)
+	return v[pos]&mask != 0
+}
+
+// Free marks a sector as not in use.
+func (v *VTOC) Free(track, sector int) {
+	pos, mask := v.bitmap(track, sector)
+	v[pos] |= mask
+}
+
+// Allocate marks a sector as in use.
+func (v *VTOC) Allocate(track, sector int) {
+	pos, mask := v.bitmap(track, sector)
+	v[pos] &^= mask
+}
+
+// The bitmap of each track takes four bytes, of which the first
+// two are used. Sectors 15-8 reside in the first, 7-0 in the second.
+func (*VTOC) bitmap(track, sector int) (int, byte) {
+	pos := 0x38 + track<<2
+	if sector < 8 {
+		pos++
+	}
+	return pos, 0x01 << (sector & 0x07)
+}
@@ -5,17 +5,37 @@ package diskette
 type (
 	// Drive is an Apple II disk drive, kind of.
 	Drive struct {
-		image *Image
-		noise *TrackReader
-		phase [0x02]byte
-		motor bool
+		image   *Image
+		noise   *BitReader
+		magnets byte // energized stepper motor phases, bit 0-3
+		quarter int  // head position in quarter-tracks
+		motor   bool
+		protect bool
+	}
+)
+
+// The head stops at track 0 (it bumps against the stop, when DOS recalibrates)
+// and at track 39. Standard images end at track 34, beyond is unformatted.
+const (
+	minQuarter = 0
+	maxQuarter = 39 << 2
+)
+
+var (
+	// Pull vectors of the four magnets.
+	magnets = [4][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+
+	// Quarter-track within a phase cycle, where the head settles.
+	settle = map[[2]int]int{
+		{1, 0}: 0, {1, 1}: 1, {0, 1}: 2, {-1, 1}: 3,
+		{-1, 0}: 4, {-1, -1}: 5, {0, -1}: 6, {1, -1}: 7,
 	}
 )
 
 // NewDrive creates an Apple II disk drive.
 func NewDrive() *Drive {
 	return &Drive{
-		noise: NewTrackReader([]byte{0x44, 0x54, 0x47, 0x00}),
+		noise: NewNoiseReader(NibbleTrackSize),
 	}
 }
 
@@ -24,65 +44,111 @@ func (d *Drive) Insert(image *Image) {
 	d.image = image
 }
 
-// Eject removes the image from this drive.
-func (d *Drive) Eject() {
+// Eject removes the image from this drive and returns it, if any.
+func (d *Drive) Eject() *Image {
+	image := d.image
 	d.image = nil
+	return image
+}
+
+// Image returns the inserted image or nil.
+func (d *Drive) Image() *Image {
+	return d.image
+}
+
+// Protect sets the write-protect state of the drive. A write-protected
+// disk can be read, but the drive does not write to it.
+func (d *Drive) Protect(state bool) {
+	d.protect = state
 }
 
-// Motor turns the diskette drive motor on/off.
+// Protected returns the write-protect state of the drive.
+func (d *Drive) Protected() bool {
+	return d.protect
+}
+
+// Motor turns the diskette drive motor on/off. The stepper
+// motor phases are powered only, when the drive is enabled.
 func (d *Drive) Motor(state bool) {
-	d.phase[0] = 0xFF
-	d.phase[1] = 0xFF
 	d.motor = state
+	d.step()
 }
 
-// Phase updates the state of a drive's stepper motor based on the current phase.
-// It maintains the previous phase state and determines the direction of movement.
+// Phase energizes or releases one of the four stepper motor magnets.
 func (d *Drive) Phase(phase byte, state bool) {
+	if state {
+		d.magnets |= 0x01 << (phase & 0x03)
+	} else {
+		d.magnets &^= 0x01 << (phase & 0x03)
+	}
+	d.step()
+}
 
-	// Do not care about the motor state, but check:
-	if d.image == nil || !state {
+// step moves the head towards the energized magnets. The magnets are two
+// quarter-tracks apart and repeat every eight quarter-tracks, so magnet
+// n pulls the head to the quarter-tracks 2n, 2n+8, 2n+16 and so on.
+// Two adjacent magnets hold the head at the quarter-track in between,
+// opposite magnets cancel each other out. The head settles instantly.
+func (d *Drive) step() {
+	if !d.motor {
 		return
 	}
 
-	// Translate the four phases in stepper movements. Track 0 resides
-	// at the outermost disk location. This implementation is incomplete.
-	// I hope, DOS 3.3 recalibration forgives me.
-	P0, P1 := byte(0), byte(1)
-	P2, P3 := byte(2), byte(3)
-
-	// Mapping between current phase, last phase, and movement direction.
-	phases := []struct {
-		this byte
-		last byte
-		dir  byte
-	}{
-		{P0, P1, 0}, {P1, P2, 0}, {P2, P3, 0}, {P3, P0, 0}, // outward
-		{P0, P3, 1}, {P1, P0, 1}, {P2, P1, 1}, {P3, P2, 1}, // inward
+	// Sum up the pull of the magnets as vectors around the eight
+	// quarter-track positions of a phase cycle.
+	x, y := 0, 0
+	for n, v := range magnets {
+		if d.magnets>>n&0x01 != 0 {
+			x, y = x+v[0], y+v[1]
+		}
 	}
-	movement := [2]func(){
-		d.image.halfTrackOut,
-		d.image.halfTrackIn,
+	t, ok := settle[[2]int{x, y}]
+	if !ok {
+		return
 	}
 
-	// Unshift current phase.
-	d.phase[1] = d.phase[0]
-	d.phase[0] = phase & 0x03
+	// Move the shortest way, a magnet on the opposite side does not pull.
+	delta := (t - d.quarter&0x07 + 8) & 0x07
+	switch {
+	case delta == 4:
+		return
+	case delta > 4:
+		delta -= 8
+	}
+	d.seek(min(max(d.quarter+delta, minQuarter), maxQuarter))
+}
 
-	// Determine the direction of head movement based on the current
-	// and previous phases. Last repelling magnet determines direction.
-	for _, p := range phases {
-		if d.phase[0] == p.this && d.phase[1] == p.last {
-			movement[p.dir]()
-			break
-		}
+// seek moves the head to the quarter-track. Reading continues at
+// the same angular position, even when the tracks differ in length.
+func (d *Drive) seek(quarter int) {
+	from := d.track()
+	d.quarter = quarter
+
+	if to := d.track(); to != from && from.count > 0 {
+		to.pos = from.pos * to.count / from.count
 	}
 }
 
-// TrackReader returns the reader for the current half-/track.
-func (d *Drive) TrackReader() *TrackReader {
+// track returns the bit stream under the head.
+func (d *Drive) track() *BitReader {
 	if d.image == nil {
 		return d.noise
 	}
-	return d.image.trackReader()
+	return d.image.reader(d.quarter)
+}
+
+// status returns the write-protect sense line in bit 7.
+func (d *Drive) status() byte {
+	if d.protect {
+		return 0x80
+	}
+	return 0x00
+}
+
+// timing returns the duration of a bit cell in 125 ns units.
+func (d *Drive) timing() uint64 {
+	if d.image == nil {
+		return bitTiming
+	}
+	return d.image.timing
 }
@@ -8,7 +8,10 @@ import (
 
 type (
 	// Encoder is track/sector encoder.
-	Encoder struct{}
+	Encoder struct {
+		order  SectorOrder
+		volume byte
+	}
 )
 
 var (
@@ -17,6 +20,14 @@ var (
 	dataPrologue = []byte{0xD5, 0xAA, 0xAD}
 	dataEpilogue = []byte{0xDE, 0xAA, 0xEB}
 
+	// Self-sync bytes between the address and the data field (gap 2) and
+	// after each data field (gap 3). When DOS rewrites a data field, it
+	// leads with five sync bytes and overshoots the former data epilogue.
+	// The gap before the first sector (gap 1) fills the track up to the
+	// length of a real track.
+	gap2 = bytes.Repeat([]byte{0xFF}, 6)
+	gap3 = bytes.Repeat([]byte{0xFF}, 27)
+
 	sixAndTwo = []byte{
 		0x96, 0x97, 0x9A, 0x9B, 0x9D, 0x9E, 0x9F, 0xA6,
 		0xA7, 0xAB, 0xAC, 0xAD, 0xAE, 0xAF, 0xB2, 0xB3,
@@ -29,33 +40,53 @@ var (
 	}
 )
 
-// NewEncoder creates a new track/sector encoder.
-func NewEncoder() *Encoder {
-	return &Encoder{}
+// DefaultVolume is the disk volume number in the address fields,
+// unless the image provides one.
+const DefaultVolume = 0xFE
+
+// NewEncoder creates a new track/sector encoder. The order
+// determines the position of the sectors in the track data.
+func NewEncoder(order SectorOrder) *Encoder {
+	return &Encoder{order: order, volume: DefaultVolume}
 }
 
-// Encode translates the pure track data into a byte stream,
-// that is suitable for reading by the Apple Disk II ROM.
+// SetVolume sets the disk volume number (1-254) of the address fields.
+// Some software checks it, DOS 3.3 compares it with the VTOC.
+func (e *Encoder) SetVolume(vol byte) {
+	if vol > 0 && vol < 0xFF {
+		e.volume = vol
+	}
+}
+
+// Encode translates the pure track data into a byte stream of the
+// length of a real track (6656 nibbles), that is suitable for reading
+// by the Apple Disk II ROM.
 func (e *Encoder) Encode(track *Track) []byte {
-	vol := byte(0xFE)
+	vol := e.volume
 	buf := bytes.Buffer{}
 
-	var sec byte
 	for num := range track.sectors {
-		if sec = byte(num); num != 15 {
-			sec = byte((num * 7) % 15)
-		}
+		sec := e.order[num]
+
 		_, _ = buf.Write(addrPrologue)
 		_, _ = buf.Write(e.fourAndFour(vol))
 		_, _ = buf.Write(e.fourAndFour(byte(track.track)))
 		_, _ = buf.Write(e.fourAndFour(byte(num)))
 		_, _ = buf.Write(e.fourAndFour(vol ^ byte(track.track) ^ byte(num)))
 		_, _ = buf.Write(addrEpilogue)
+		_, _ = buf.Write(gap2)
 		_, _ = buf.Write(dataPrologue)
 		_, _ = buf.Write(e.sixAndTwo(track.sectors[sec][:]))
 		_, _ = buf.Write(dataEpilogue)
+		_, _ = buf.Write(gap3)
 	}
-	return buf.Bytes()
+	return e.gap1(buf.Bytes())
+}
+
+// gap1 puts sync bytes in front of the sectors, up to the track length.
+func (*Encoder) gap1(sectors []byte) []byte {
+	n := max(NibbleTrackSize-len(sectors), len(gap3))
+	return append(bytes.Repeat([]byte{0xFF}, n), sectors...)
 }
 
 // Borrowed from https://github.com/TomHarte/dsk2woz (MIT License)
@@ -3,20 +3,34 @@
 package diskette
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"io"
+	"sync"
 )
 
 type (
-	// Image is a DOS 3.3 disk (.dsk) image.
+	// Image is a 16 or 13 sector disk, a nibble or a WOZ image.
 	// Track 0 is at the outermost location.
 	Image struct {
-		tracks    [35 * 2]Track
-		readers   [35 * 2]Reader
+		tracks    [40 * 2]Track
+		count     int // tracks of a sector image, 35 or 40
+		readers   [40 * 4]*BitReader
+		blank     *BitReader
+		nibbles   []byte // tracks of a nibble image, as saved last
 		encoder   *Encoder
 		decoder   *Decoder
-		halfTrack int
+		format    Format
+		meta      map[string]string
+		container *twoIMG
+		timing    uint64
+		mu        sync.Mutex
 	}
 
+	// Format is the file format of a disk image.
+	Format string
+
 	// Track represents a disk track with sectors.
 	Track struct {
 		sectors [16]sector
@@ -26,49 +40,111 @@ type (
 	sector [0x100]byte
 )
 
+const (
+	// FormatDSK is a 16 sector image (.dsk, .do, .po).
+	FormatDSK Format = "dsk"
+
+	// FormatD13 is a 13 sector DOS 3.2 image (.d13).
+	FormatD13 Format = "d13"
+
+	// FormatNIB is a raw nibble image (.nib).
+	FormatNIB Format = "nib"
+
+	// FormatWOZ is a WOZ 1.0 or 2.0 bit stream image (.woz).
+	FormatWOZ Format = "woz"
+
+	// ImageSize is the size of a 16 sector image with 35 tracks (140K).
+	ImageSize = 35 * 0x10 * 0x100
+
+	// Image40Size is the size of a 16 sector image with 40 tracks (160K).
+	Image40Size = 40 * 0x10 * 0x100
+)
+
+// ErrTrack is returned by WriteTo, when written tracks can not be
+// decoded. The image is written anyway, these tracks keep the sectors
+// they had before.
+var ErrTrack = errors.New("track not decodable")
+
 // NewStandardImage creates image with a standard encoder and decoder.
 func NewStandardImage() *Image {
-	return NewImage(NewEncoder(), NewDecoder())
+	return NewOrderedImage(DOSOrder)
+}
+
+// NewOrderedImage creates image with an encoder and decoder for the sector order.
+func NewOrderedImage(order SectorOrder) *Image {
+	return NewImage(NewEncoder(order), NewDecoder(order))
+}
+
+// NewBlankImage creates a 16 sector image with empty sectors.
+func NewBlankImage(order SectorOrder) *Image {
+	return NewOrderedImage(order).MustLoad(bytes.NewReader(make([]byte, ImageSize)))
 }
 
 // NewImage creates a new disk image.
 func NewImage(encoder *Encoder, decoder *Decoder) *Image {
 	return &Image{
+		blank:   NewNoiseReader(NibbleTrackSize),
 		encoder: encoder,
 		decoder: decoder,
+		format:  FormatDSK,
+		count:   35,
+		timing:  bitTiming,
 	}
 }
 
-// Load loads a disk image and prepares track readers for use with Drive.
+// Load loads a 16 sector disk image and prepares track readers for use
+// with Drive. Images have 35 or 40 tracks, shorter images are rejected.
 func (im *Image) Load(r io.Reader) error {
-	buf := make([]byte, 0x100)
 
 	// Populate tracks, skip half-tracks.
-	for t, n := 0, len(im.tracks); t < n; t += 2 {
+	im.count = 0
+tracks:
+	for t := 0; t < len(im.tracks)>>1; t++ {
 		for s := 0; s < 0x10; s++ {
-			num, err := r.Read(buf)
-
-			if num == 0 || err == io.EOF {
-				break
-			} else if err != nil {
+			_, err := io.ReadFull(r, im.tracks[t<<1].sectors[s][:])
+			switch {
+			case err == io.EOF && t == 35 && s == 0:
+				break tracks
+			case err == io.EOF || err == io.ErrUnexpectedEOF:
+				return fmt.Errorf("image truncated at track %d, sector %d", t, s)
+			case err != nil:
 				return err
 			}
-			copy(im.tracks[t].sectors[s][:], buf)
 		}
+		im.count = t + 1
 	}
 
-	// Each track and (empty) half-track has its own reader.
-	for t, n := 0, len(im.tracks); t < n; t++ {
+	// The address fields carry the volume number of the DOS catalog.
+	im.encoder.SetVolume(im.vtocVolume(0x10))
+	im.encodeTracks()
+	im.format = FormatDSK
+	return nil
+}
+
+// encodeTracks prepares the track readers of a 16 sector image.
+// Each track and (empty) half-track has its own reader.
+func (im *Image) encodeTracks() {
+	for t, n := 0, im.count<<1; t < n; t++ {
 		if t&0x01 == 0 {
 			im.tracks[t].track = t >> 1
 		} else {
 			im.tracks[t].track = 0xFF - t
 		}
-		im.readers[t] = NewTrackReader(
+		im.readers[t<<1] = NewNibbleReader(
 			im.encoder.Encode(&im.tracks[t]),
 		)
 	}
-	return nil
+	im.quarterTracks()
+}
+
+// vtocVolume returns the volume number of a DOS VTOC in track 17,
+// sector 0, or 0. The sector count tells DOS 3.3 and 3.2 apart.
+func (im *Image) vtocVolume(sectors byte) byte {
+	vtoc := im.tracks[0x11<<1].sectors[0]
+	if vtoc[0x27] != 0x7A || vtoc[0x35] != sectors {
+		return 0
+	}
+	return vtoc[0x06]
 }
 
 // MustLoad panics if the disk image can not be loaded.
@@ -79,18 +155,207 @@ func (im *Image) MustLoad(r io.Reader) *Image {
 	return im
 }
 
-func (im *Image) halfTrackIn() {
-	if im.halfTrack++; im.halfTrack >= len(im.tracks) {
-		im.halfTrack = len(im.tracks) - 1
+// Dirty signals, whether the image has been written to since
+// it was loaded or since the last call to WriteTo.
+func (im *Image) Dirty() bool {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	for _, r := range im.readers {
+		if r != nil && r.Dirty() {
+			return true
+		}
+	}
+	return false
+}
+
+// Format returns the file format of the image. The format of
+// images in a 2IMG container is the format of the image data.
+func (im *Image) Format() Format {
+	return im.format
+}
+
+// Container returns Format2IMG for images in a 2IMG container, or "".
+func (im *Image) Container() Format {
+	if im.container != nil {
+		return Format2IMG
+	}
+	return ""
+}
+
+// Order returns the sector order of the image data.
+func (im *Image) Order() SectorOrder {
+	return im.encoder.order
+}
+
+// Meta returns the metadata of the image, if provided by the format.
+func (im *Image) Meta() map[string]string {
+	return im.meta
+}
+
+// WriteTo writes the disk image to w, in the format it was loaded from.
+// The image is no longer dirty when all tracks have been written. Tracks,
+// that can not be decoded, are reported with ErrTrack, the image has
+// been written completely nevertheless.
+func (im *Image) WriteTo(w io.Writer) (int64, error) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	if im.container != nil {
+		return im.container.write(w, im.writeFormat)
+	}
+	return im.writeFormat(w)
+}
+
+// Writable signals, whether the image can be written in its format.
+// WOZ images are read only.
+func (im *Image) Writable() bool {
+	switch im.format {
+	case FormatDSK, FormatD13, FormatNIB:
+		return true
+	}
+	return false
+}
+
+func (im *Image) writeFormat(w io.Writer) (int64, error) {
+	switch im.format {
+	case FormatDSK:
+		return im.writeSectors(w)
+	case FormatD13:
+		return im.writeSectors13(w)
+	case FormatNIB:
+		return im.writeNibbles(w)
+	}
+	return 0, fmt.Errorf("%s images can not be written", im.format)
+}
+
+// writeSectors decodes written tracks and writes the sectors to w.
+func (im *Image) writeSectors(w io.Writer) (int64, error) {
+	lost := im.decodeTracks(im.decoder.Decode)
+
+	total := int64(0)
+	for t, n := 0, im.count<<1; t < n; t += 2 {
+		for s := 0; s < 0x10; s++ {
+			num, err := w.Write(im.tracks[t].sectors[s][:])
+			if total += int64(num); err != nil {
+				return total, err
+			}
+		}
+	}
+	return total, lost
+}
+
+// decodeTracks decodes the written tracks back into sectors. A track,
+// that fails to decode, keeps its former sectors and is reported with
+// ErrTrack. It is not reported again, unless it is written again.
+func (im *Image) decodeTracks(decode func(stream []byte) (*Track, error)) error {
+	var lost error
+
+	// Skip half-tracks.
+	for t, n := 0, im.count<<1; t < n; t += 2 {
+		r := im.readers[t<<1]
+		if !r.dirty {
+			continue
+		}
+		r.dirty = false
+
+		track, err := decode(r.nibbles())
+		if err == nil && track.track != t>>1 {
+			err = fmt.Errorf("found track %d", track.track)
+		}
+		if err != nil {
+			lost = errors.Join(lost, fmt.Errorf("track %d: %w: %w", t>>1, ErrTrack, err))
+			continue
+		}
+		im.tracks[t].sectors = track.sectors
+	}
+
+	// Writes to half-tracks are not representable.
+	for t, n := 1, im.count<<1; t < n; t += 2 {
+		im.readers[t<<1].dirty = false
+	}
+	return lost
+}
+
+// ReadSector returns a copy of a sector of a 16 sector image. The sector
+// number is logical, as seen by a file system with the order (DOSOrder
+// for DOS 3.3, ProDOSOrder for ProDOS), independent of the image order.
+// Writes of the emulated drive are not reflected until WriteTo. The tracks
+// of nibble and WOZ images are decoded, when they are standard tracks.
+func (im *Image) ReadSector(track, sector int, order SectorOrder) ([]byte, error) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	t, s, err := im.sector(track, sector, order)
+	if err != nil {
+		return nil, err
+	}
+
+	switch im.format {
+	case FormatDSK:
+		return append([]byte{}, im.tracks[t].sectors[s][:]...), nil
+	case FormatNIB, FormatWOZ:
+		r := im.readers[t<<1]
+		if r == nil {
+			return nil, fmt.Errorf("track %d: not on disk", track)
+		}
+		trk, err := im.decoder.Decode(r.nibbles())
+		if err != nil {
+			return nil, err
+		}
+		if trk.track != track {
+			return nil, fmt.Errorf("track %d: found track %d", track, trk.track)
+		}
+		return append([]byte{}, trk.sectors[s][:]...), nil
+	}
+	return nil, fmt.Errorf("%s images have no sector access", im.format)
+}
+
+// WriteSector replaces a sector of a 16 sector image, see ReadSector.
+// The track is encoded again, the image is dirty afterwards.
+func (im *Image) WriteSector(track, sector int, order SectorOrder, data []byte) error {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	if im.format != FormatDSK {
+		return fmt.Errorf("%s images have no sector write access", im.format)
+	}
+	t, s, err := im.sector(track, sector, order)
+	if err != nil {
+		return err
+	}
+	copy(im.tracks[t].sectors[s][:], data)
+
+	r := NewNibbleReader(im.encoder.Encode(&im.tracks[t]))
+	r.dirty = true
+	im.readers[t<<1] = r
+	im.quarterTracks()
+	return nil
+}
+
+// sector maps a logical sector to its index in tracks and sectors.
+func (im *Image) sector(track, sector int, order SectorOrder) (int, int, error) {
+	if track < 0 || track >= im.count || sector < 0 || sector > 0x0F {
+		return 0, 0, fmt.Errorf("track %d, sector %d: out of range", track, sector)
 	}
+	return track << 1, int(im.encoder.order[order.physical(sector)]), nil
 }
 
-func (im *Image) halfTrackOut() {
-	if im.halfTrack--; im.halfTrack < 0 {
-		im.halfTrack = 0
+// quarterTracks maps the quarter-tracks between tracks and half-tracks.
+// At a quarter-track, the head picks up the nearest track.
+func (im *Image) quarterTracks() {
+	for q, n := 1, len(im.readers); q < n; q += 2 {
+		if t := (q + 1) &^ 0x03; t < n {
+			im.readers[q] = im.readers[t]
+		}
 	}
 }
 
-func (im *Image) trackReader() *TrackReader {
-	return im.readers[im.halfTrack].(*TrackReader)
+// reader returns the reader at the quarter-track. Unformatted
+// locations of the disk provide random noise.
+func (im *Image) reader(quarter int) *BitReader {
+	if quarter >= 0 && quarter < len(im.readers) && im.readers[quarter] != nil {
+		return im.readers[quarter]
+	}
+	return im.blank
 }
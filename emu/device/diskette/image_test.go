@@ -0,0 +1,43 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package diskette
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestImage40Tracks(t *testing.T) {
+	data := make([]byte, Image40Size)
+	for i := range data {
+		data[i] = byte(i>>8 + i>>12)
+	}
+
+	format, err := Sniff("40.dsk", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != FormatDSK {
+		t.Fatalf("got format %s, expected %s", format, FormatDSK)
+	}
+
+	im := NewOrderedImage(DOSOrder)
+	if err = im.Load(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	sec, err := im.ReadSector(39, 0, DOSOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos := 39 << 12; !bytes.Equal(sec, data[pos:pos+0x100]) {
+		t.Errorf("track 39, sector 0: got % X", sec[:8])
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err = im.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("saved %d bytes, differ from the %d loaded", buf.Len(), len(data))
+	}
+}
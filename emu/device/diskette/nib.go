@@ -0,0 +1,101 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package diskette
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// NibbleTrackSize is the number of nibbles per track of a .nib image.
+	NibbleTrackSize = 6656
+
+	// NibbleImageSize is the size of a .nib image with 35 tracks.
+	NibbleImageSize = 35 * NibbleTrackSize
+)
+
+// LoadNIB loads a raw nibble (.nib) image. The nibbles are passed to the
+// track readers as they are, preserving non-standard sync patterns.
+// The bit timing of sync bytes is not part of the format, runs of 0xFF
+// are taken as sync bytes.
+func (im *Image) LoadNIB(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(data) != NibbleImageSize {
+		return fmt.Errorf("nibble image size %d, expected %d", len(data), NibbleImageSize)
+	}
+
+	// There is nothing between the tracks.
+	for q := range im.readers {
+		im.readers[q] = nil
+	}
+	for t := 0; t < 35; t++ {
+		buf := data[t*NibbleTrackSize : (t+1)*NibbleTrackSize]
+		im.readers[t<<2] = NewNibbleReader(buf)
+	}
+
+	im.quarterTracks()
+	im.nibbles = data
+	im.format = FormatNIB
+	return nil
+}
+
+// writeNibbles writes the nibbles of all tracks to w. Tracks, that have
+// not been written to, are saved as they were loaded. Written tracks are
+// cut or filled up with sync, when they have more or less nibbles. Written
+// tracks without nibbles keep their former content, see ErrTrack.
+func (im *Image) writeNibbles(w io.Writer) (int64, error) {
+	var lost error
+
+	total := int64(0)
+	for t := 0; t < 35; t++ {
+		r := im.readers[t<<2]
+		buf := im.nibbles[t*NibbleTrackSize : (t+1)*NibbleTrackSize]
+		if r.dirty {
+			if nibbles := r.nibbles(); len(nibbles) > 0 {
+				copy(buf, alignNibbles(nibbles, NibbleTrackSize))
+			} else {
+				lost = errors.Join(lost, fmt.Errorf("track %d: %w: no nibbles", t, ErrTrack))
+			}
+		}
+
+		num, err := w.Write(buf)
+		if total += int64(num); err != nil {
+			return total, err
+		}
+		r.dirty = false
+	}
+	return total, lost
+}
+
+// alignNibbles rotates the nibbles of a track, so that the track starts
+// in the middle of the longest sync gap, and cuts or fills up the end of
+// the gap with sync bytes to the size. Fields do not get torn apart.
+func alignNibbles(nibbles []byte, size int) []byte {
+	n := len(nibbles)
+	from, best := 0, 0
+
+	for i := 0; i < n; i++ {
+		if nibbles[i] != 0xFF || nibbles[(i+n-1)%n] == 0xFF {
+			continue
+		}
+		run := 0
+		for run < n && nibbles[(i+run)%n] == 0xFF {
+			run++
+		}
+		if run > best {
+			from, best = (i+run/2)%n, run
+		}
+	}
+
+	buf := make([]byte, size)
+	for i := range buf {
+		buf[i] = 0xFF
+	}
+	copy(buf, append(append([]byte{}, nibbles[from:]...), nibbles[:from]...))
+	return buf
+}
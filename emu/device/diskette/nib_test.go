@@ -0,0 +1,32 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package diskette
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNIBSavedUnchanged(t *testing.T) {
+	enc := NewEncoder(DOSOrder)
+
+	// Tracks starting anywhere, not in a sync gap.
+	data := make([]byte, 0, NibbleImageSize)
+	for n := 0; n < 35; n++ {
+		trk := alignNibbles(enc.Encode(&Track{track: n}), NibbleTrackSize)
+		from := n * 123 % NibbleTrackSize
+		data = append(append(data, trk[from:]...), trk[:from]...)
+	}
+
+	im := NewStandardImage()
+	if err := im.LoadNIB(bytes.NewReader(append([]byte{}, data...))); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if _, err := im.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Error("unmodified image saved with changes")
+	}
+}
@@ -0,0 +1,138 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package diskette
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+type (
+	// SectorOrder maps the physical sector number on a track
+	// (index) to the position of the sector in the image file.
+	SectorOrder [16]byte
+)
+
+var (
+	// DOSOrder is the logical sector order of DOS 3.3 (.dsk, .do) images.
+	DOSOrder = SectorOrder{
+		0x00, 0x07, 0x0E, 0x06, 0x0D, 0x05, 0x0C, 0x04,
+		0x0B, 0x03, 0x0A, 0x02, 0x09, 0x01, 0x08, 0x0F,
+	}
+
+	// ProDOSOrder is the block order of ProDOS (.po) images.
+	ProDOSOrder = SectorOrder{
+		0x00, 0x08, 0x01, 0x09, 0x02, 0x0A, 0x03, 0x0B,
+		0x04, 0x0C, 0x05, 0x0D, 0x06, 0x0E, 0x07, 0x0F,
+	}
+)
+
+// ParseOrder returns the SectorOrder by name ("dos" or "prodos").
+func ParseOrder(name string) (SectorOrder, error) {
+	switch strings.ToLower(name) {
+	case "dos", "do", "dsk":
+		return DOSOrder, nil
+	case "prodos", "po":
+		return ProDOSOrder, nil
+	}
+	return SectorOrder{}, fmt.Errorf("unknown sector order %q", name)
+}
+
+// DetectOrder guesses the sector order of an image by the file name extension.
+// For ambiguous extensions (.dsk), the image data is probed for the catalog
+// of a DOS 3.3 and for the volume directory of a ProDOS file system.
+func DetectOrder(name string, data []byte) SectorOrder {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".po":
+		return ProDOSOrder
+	case ".do":
+		return DOSOrder
+	}
+	dos := max(probeDOS(data, DOSOrder), probeProDOS(data, DOSOrder))
+	pro := max(probeDOS(data, ProDOSOrder), probeProDOS(data, ProDOSOrder))
+
+	if pro > dos {
+		return ProDOSOrder
+	}
+	return DOSOrder
+}
+
+// String returns the common name of the order.
+func (o SectorOrder) String() string {
+	switch o {
+	case DOSOrder:
+		return "dos"
+	case ProDOSOrder:
+		return "prodos"
+	}
+	return "custom"
+}
+
+// physical returns the physical sector for the sector position in a file.
+func (o SectorOrder) physical(sec int) int {
+	for p, s := range o {
+		if int(s) == sec&0x0F {
+			return p
+		}
+	}
+	return 0
+}
+
+// fileSector returns a sector of a file system, that expects its sectors in
+// the want order, from the image data, that is stored in the file order.
+func fileSector(data []byte, file, want SectorOrder, track, sec int) []byte {
+	pos := (track<<4 | int(file[want.physical(sec)])) << 8
+	if track < 0 || pos+0x100 > len(data) {
+		return make([]byte, 0x100)
+	}
+	return data[pos : pos+0x100]
+}
+
+// probeDOS counts the catalog sectors of a DOS 3.3 file system.
+func probeDOS(data []byte, file SectorOrder) int {
+	vtoc := fileSector(data, file, DOSOrder, 0x11, 0x00)
+	if vtoc[0x27] != 0x7A || vtoc[0x35] != 0x10 {
+		return 0
+	}
+	count := 0
+	trk, sec := int(vtoc[0x01]), int(vtoc[0x02])
+
+	// Follow the catalog chain.
+	for i := 0; i < 0x10 && trk > 0 && trk < 0x23 && sec < 0x10; i++ {
+		cat := fileSector(data, file, DOSOrder, trk, sec)
+		trk, sec = int(cat[0x01]), int(cat[0x02])
+		count++
+	}
+	return count
+}
+
+// probeProDOS counts the blocks of a ProDOS volume directory.
+func probeProDOS(data []byte, file SectorOrder) int {
+	block := func(num int) []byte {
+		trk, sec := num>>3, (num&0x07)<<1
+		return append(
+			append([]byte{}, fileSector(data, file, ProDOSOrder, trk, sec)...),
+			fileSector(data, file, ProDOSOrder, trk, sec+1)...,
+		)
+	}
+
+	// Volume directory header in key block 2.
+	dir := block(2)
+	if dir[0x00] != 0 || dir[0x01] != 0 || dir[0x04]>>4 != 0x0F || dir[0x23] != 0x27 {
+		return 0
+	}
+	count := 1
+	prev, next := 2, int(dir[0x02])|int(dir[0x03])<<8
+
+	// Follow the directory chain.
+	for i := 0; i < 0x10 && next > 2 && next < 0x118; i++ {
+		dir = block(next)
+		if int(dir[0x00])|int(dir[0x01])<<8 != prev {
+			break
+		}
+		prev, next = next, int(dir[0x02])|int(dir[0x03])<<8
+		count++
+	}
+	return count
+}
@@ -0,0 +1,212 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package prodos
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type (
+	// Entry is a file or subdirectory entry of a directory.
+	Entry struct {
+		Name     string
+		Storage  byte
+		Type     FileType
+		Key      int
+		Blocks   int
+		EOF      int
+		Access   byte
+		Aux      int
+		Created  time.Time
+		Modified time.Time
+
+		// Location of the entry, key block of its directory.
+		block  int
+		offset int
+		header int
+	}
+
+	// FileType is the ProDOS file type.
+	FileType byte
+)
+
+// Storage types, the upper nibble of the first byte of an entry.
+const (
+	storageDeleted  = 0x00
+	storageSeedling = 0x01
+	storageSapling  = 0x02
+	storageTree     = 0x03
+	storageDir      = 0x0D
+	storageSubdir   = 0x0E
+	storageVolume   = 0x0F
+)
+
+// File types with a name.
+const (
+	TypeTXT FileType = 0x04
+	TypeBIN FileType = 0x06
+	TypeDIR FileType = 0x0F
+	TypeINT FileType = 0xFA
+	TypeBAS FileType = 0xFC
+	TypeSYS FileType = 0xFF
+)
+
+const (
+	entrySize    = 0x27
+	entriesBlock = 0x0D
+	nameSize     = 15
+
+	// Access: destroy, rename, backup, write and read enabled.
+	accessUnlocked = 0xE3
+	accessWrite    = 0x02
+)
+
+var typeNames = map[FileType]string{
+	0x00: "UNK", 0x01: "BAD", TypeTXT: "TXT", TypeBIN: "BIN", TypeDIR: "DIR",
+	0x19: "ADB", 0x1A: "AWP", 0x1B: "ASP", 0xEF: "PAS", 0xF0: "CMD",
+	TypeINT: "INT", 0xFB: "IVR", TypeBAS: "BAS", 0xFD: "VAR", 0xFE: "REL",
+	TypeSYS: "SYS",
+}
+
+// ParseType returns the FileType for its name (e.g. "BIN") or number ($06).
+// The DOS 3.3 letters T, I, A and B are accepted for TXT, INT, BAS and BIN.
+func ParseType(name string) (FileType, error) {
+	name = strings.ToUpper(name)
+	switch name {
+	case "T":
+		return TypeTXT, nil
+	case "I":
+		return TypeINT, nil
+	case "A":
+		return TypeBAS, nil
+	case "B":
+		return TypeBIN, nil
+	}
+	for t, n := range typeNames {
+		if n == name {
+			return t, nil
+		}
+	}
+	var t FileType
+	if _, err := fmt.Sscanf(strings.Replace(name, "$", "0X", 1), "0X%02X", &t); err == nil {
+		return t, nil
+	}
+	return 0, fmt.Errorf("unknown file type %q", name)
+}
+
+// String returns the name of the file type, or its number.
+func (t FileType) String() string {
+	if n, ok := typeNames[t]; ok {
+		return n
+	}
+	return fmt.Sprintf("$%02X", byte(t))
+}
+
+// IsDir signals, whether the entry is a subdirectory.
+func (e Entry) IsDir() bool {
+	return e.Storage == storageDir
+}
+
+// Locked signals, whether the file is write-protected.
+func (e Entry) Locked() bool {
+	return e.Access&accessWrite == 0
+}
+
+// String returns the catalog line of the entry, as shown by CATALOG.
+func (e Entry) String() string {
+	lock := " "
+	if e.Locked() {
+		lock = "*"
+	}
+	aux := ""
+	switch e.Type {
+	case TypeBIN, TypeBAS, TypeSYS, TypeTXT:
+		aux = fmt.Sprintf("$%04X", e.Aux)
+	}
+	return fmt.Sprintf("%s%-15s %s %6d  %s %8d  %s",
+		lock, e.Name, e.Type, e.Blocks, formatTime(e.Modified), e.EOF, aux,
+	)
+}
+
+// parseEntry parses the directory entry at the buffer start.
+func parseEntry(b []byte) Entry {
+	return Entry{
+		Name:     string(b[0x01 : 0x01+b[0x00]&0x0F]),
+		Storage:  b[0x00] >> 4,
+		Type:     FileType(b[0x10]),
+		Key:      word(b[0x11:]),
+		Blocks:   word(b[0x13:]),
+		EOF:      word(b[0x15:]) | int(b[0x17])<<16,
+		Created:  parseTime(b[0x18:]),
+		Access:   b[0x1E],
+		Aux:      word(b[0x1F:]),
+		Modified: parseTime(b[0x21:]),
+		header:   word(b[0x25:]),
+	}
+}
+
+// marshal writes the entry to the buffer start.
+func (e Entry) marshal(b []byte) {
+	clear(b[:entrySize])
+	b[0x00] = e.Storage<<4 | byte(len(e.Name))
+	copy(b[0x01:0x10], e.Name)
+	b[0x10] = byte(e.Type)
+	putWord(b[0x11:], e.Key)
+	putWord(b[0x13:], e.Blocks)
+	putWord(b[0x15:], e.EOF)
+	b[0x17] = byte(e.EOF >> 16)
+	putTime(b[0x18:], e.Created)
+	b[0x1E] = e.Access
+	putWord(b[0x1F:], e.Aux)
+	putTime(b[0x21:], e.Modified)
+	putWord(b[0x25:], e.header)
+}
+
+// checkName returns the upper case file name, when valid: up to 15
+// letters, digits and periods, starting with a letter.
+func checkName(name string) (string, error) {
+	name = strings.ToUpper(name)
+	if name == "" || len(name) > nameSize || name[0] < 'A' || name[0] > 'Z' {
+		return "", fmt.Errorf("%s: invalid file name", name)
+	}
+	for _, c := range []byte(name) {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '.' {
+			return "", fmt.Errorf("%s: invalid file name", name)
+		}
+	}
+	return name, nil
+}
+
+// Dates are stored as yyyyyyym mmmddddd, times as 000hhhhh 00mmmmmm.
+// Years 40-99 are 1940-1999, years 0-39 are 2000-2039.
+func parseTime(b []byte) time.Time {
+	date := word(b)
+	if date == 0 {
+		return time.Time{}
+	}
+	year := date >> 9
+	if year < 40 {
+		year += 100
+	}
+	return time.Date(
+		1900+year, time.Month(date>>5&0x0F), date&0x1F,
+		int(b[3]&0x1F), int(b[2]&0x3F), 0, 0, time.Local,
+	)
+}
+
+func putTime(b []byte, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	putWord(b, t.Year()%100<<9|int(t.Month())<<5|t.Day())
+	b[2], b[3] = byte(t.Minute()), byte(t.Hour())
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "<NO DATE>       "
+	}
+	return strings.ToUpper(t.Format("02-Jan-06 15:04"))
+}
@@ -0,0 +1,538 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package prodos
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+type (
+	// File is the content of a file.
+	File struct {
+		Name   string
+		Type   FileType
+		Aux    int // Load address of BIN, BAS and SYS files.
+		Locked bool
+		Data   []byte
+	}
+)
+
+// Access of locked files: only read and backup enabled.
+const accessLocked = 0x21
+
+// ReadDir returns the entries of the directory at the path. Paths are
+// separated by slashes and relative to the volume directory, a leading
+// slash must be followed by the volume name.
+func (v *Volume) ReadDir(path string) ([]Entry, error) {
+	dir, err := v.Lookup(path)
+	if err != nil {
+		return nil, err
+	}
+	if !dir.IsDir() {
+		return nil, fmt.Errorf("%s: not a directory", path)
+	}
+	list := []Entry{}
+	err = v.walkDir(dir.Key, func(e Entry) bool {
+		if e.Storage != storageDeleted {
+			list = append(list, e)
+		}
+		return true
+	})
+	return list, err
+}
+
+// Lookup returns the entry at the path, see ReadDir.
+func (v *Volume) Lookup(name string) (Entry, error) {
+	e := v.root()
+	parts := strings.Split(strings.Trim(name, "/"), "/")
+
+	if strings.HasPrefix(name, "/") {
+		if !strings.EqualFold(parts[0], v.name) {
+			return Entry{}, fmt.Errorf("%s: %w", name, ErrNotFound)
+		}
+		parts = parts[1:]
+	}
+
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+		if !e.IsDir() {
+			return Entry{}, fmt.Errorf("%s: %w", name, ErrNotFound)
+		}
+		found := false
+		err := v.walkDir(e.Key, func(c Entry) bool {
+			if c.Storage != storageDeleted && strings.EqualFold(c.Name, part) {
+				e, found = c, true
+			}
+			return !found
+		})
+		if err != nil {
+			return Entry{}, err
+		}
+		if !found {
+			return Entry{}, fmt.Errorf("%s: %w", name, ErrNotFound)
+		}
+	}
+	return e, nil
+}
+
+// ReadFile returns the file at the path.
+func (v *Volume) ReadFile(name string) (*File, error) {
+	e, err := v.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if e.IsDir() {
+		return nil, fmt.Errorf("%s: is a directory", name)
+	}
+	blocks, err := v.dataBlocks(e)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, 0, len(blocks)*BlockSize)
+	for _, num := range blocks {
+		if num == 0 {
+			data = append(data, make([]byte, BlockSize)...)
+			continue
+		}
+		buf, err := v.ReadBlock(num)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, buf...)
+	}
+
+	return &File{
+		Name:   e.Name,
+		Type:   e.Type,
+		Aux:    e.Aux,
+		Locked: e.Locked(),
+		Data:   data[:min(e.EOF, len(data))],
+	}, nil
+}
+
+// WriteFile stores the file in the directory at the path. An existing
+// file of the same name is replaced, unless it is locked.
+func (v *Volume) WriteFile(dir string, f *File) error {
+	name, err := checkName(f.Name)
+	if err != nil {
+		return err
+	}
+	parent, err := v.Lookup(dir)
+	if err != nil {
+		return err
+	}
+	if _, err = v.Lookup(path.Join(dir, name)); err == nil {
+		if err = v.Delete(path.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+
+	// Seedling files have one data block, sapling files an index block
+	// for up to 256 data blocks, tree files a master index block above.
+	count := max(1, (len(f.Data)+BlockSize-1)/BlockSize)
+	storage, index := byte(storageSeedling), 0
+	switch {
+	case count > 0x100:
+		storage, index = storageTree, (count+0xFF)>>8+1
+	case count > 1:
+		storage, index = storageSapling, 1
+	}
+
+	blocks, err := v.allocate(count + index)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	data, meta := blocks[:count], blocks[count:]
+
+	for i, num := range data {
+		buf := make([]byte, BlockSize)
+		copy(buf, f.Data[min(i*BlockSize, len(f.Data)):])
+		if err = v.WriteBlock(num, buf); err != nil {
+			return err
+		}
+	}
+
+	key := data[0]
+	switch storage {
+	case storageSapling:
+		key = meta[0]
+		err = v.WriteBlock(key, indexBlock(data))
+	case storageTree:
+		key = meta[0]
+		indexes := meta[1:]
+		for i, num := range indexes {
+			if err = v.WriteBlock(num, indexBlock(data[i<<8:min((i+1)<<8, len(data))])); err != nil {
+				return err
+			}
+		}
+		err = v.WriteBlock(key, indexBlock(indexes))
+	}
+	if err != nil {
+		return err
+	}
+
+	access := byte(accessUnlocked)
+	if f.Locked {
+		access = accessLocked
+	}
+	now := time.Now()
+
+	_, err = v.addEntry(parent, Entry{
+		Name:     name,
+		Storage:  storage,
+		Type:     f.Type,
+		Key:      key,
+		Blocks:   len(blocks),
+		EOF:      len(f.Data),
+		Access:   access,
+		Aux:      f.Aux,
+		Created:  now,
+		Modified: now,
+	})
+	return err
+}
+
+// Mkdir creates a subdirectory at the path.
+func (v *Volume) Mkdir(dir string) error {
+	parentPath, base := path.Split(strings.TrimSuffix(dir, "/"))
+	name, err := checkName(base)
+	if err != nil {
+		return err
+	}
+	parent, err := v.Lookup(parentPath)
+	if err != nil {
+		return err
+	}
+	if _, err = v.Lookup(path.Join(parentPath, name)); err == nil {
+		return fmt.Errorf("%s: file exists", dir)
+	}
+
+	blocks, err := v.allocate(1)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	now := time.Now()
+
+	e, err := v.addEntry(parent, Entry{
+		Name:     name,
+		Storage:  storageDir,
+		Type:     TypeDIR,
+		Key:      blocks[0],
+		Blocks:   1,
+		EOF:      BlockSize,
+		Access:   accessUnlocked,
+		Created:  now,
+		Modified: now,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The subdirectory header points back to the entry in the parent.
+	buf := make([]byte, BlockSize)
+	h := buf[0x04:]
+	h[0x00] = storageSubdir<<4 | byte(len(name))
+	copy(h[0x01:0x10], name)
+	h[0x10] = 0x75
+	putTime(h[0x18:], now)
+	h[0x1E] = accessUnlocked
+	h[0x1F] = entrySize
+	h[0x20] = entriesBlock
+	putWord(h[0x23:], e.block)
+	h[0x25] = byte((e.offset-0x04)/entrySize + 1)
+	h[0x26] = entrySize
+
+	return v.WriteBlock(blocks[0], buf)
+}
+
+// Delete removes the file or the empty subdirectory at the path
+// and frees its blocks.
+func (v *Volume) Delete(name string) error {
+	e, err := v.Lookup(name)
+	if err != nil {
+		return err
+	}
+	switch {
+	case e.Key == VolumeDir:
+		return fmt.Errorf("%s: can not delete the volume directory", name)
+	case e.Locked():
+		return fmt.Errorf("%s: %w", name, ErrLocked)
+	}
+
+	var blocks []int
+	if e.IsDir() {
+		count := 0
+		err = v.walkDir(e.Key, func(c Entry) bool {
+			if c.Storage != storageDeleted {
+				count++
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%s: directory not empty", name)
+		}
+		blocks, err = v.dirBlocks(e.Key)
+	} else {
+		blocks, err = v.fileBlocks(e)
+	}
+	if err != nil {
+		return err
+	}
+	if err = v.release(blocks); err != nil {
+		return err
+	}
+
+	buf, err := v.ReadBlock(e.block)
+	if err != nil {
+		return err
+	}
+	buf[e.offset] = 0x00
+	if err = v.WriteBlock(e.block, buf); err != nil {
+		return err
+	}
+	return v.countFiles(e.header, -1)
+}
+
+// FromText converts host text into ProDOS text: lines end with a
+// carriage return instead of a line feed.
+func FromText(b []byte) []byte {
+	buf := make([]byte, 0, len(b))
+	for _, c := range b {
+		switch c {
+		case '\r':
+			continue
+		case '\n':
+			c = '\r'
+		}
+		buf = append(buf, c&0x7F)
+	}
+	return buf
+}
+
+// ToText converts ProDOS text into host text, see FromText.
+func ToText(b []byte) []byte {
+	buf := make([]byte, 0, len(b))
+	for _, c := range b {
+		if c &= 0x7F; c == '\r' {
+			c = '\n'
+		}
+		buf = append(buf, c)
+	}
+	return buf
+}
+
+// root returns a pseudo entry for the volume directory.
+func (v *Volume) root() Entry {
+	return Entry{Name: v.name, Storage: storageDir, Type: TypeDIR, Key: VolumeDir}
+}
+
+// walkDir calls fn for each entry of the directory, including deleted
+// entries, until fn returns false. The directory header is skipped.
+func (v *Volume) walkDir(key int, fn func(e Entry) bool) error {
+	seen := map[int]bool{}
+
+	for num := key; num != 0; {
+		if seen[num] || num >= v.total {
+			return fmt.Errorf("directory: %w", ErrBroken)
+		}
+		seen[num] = true
+
+		buf, err := v.ReadBlock(num)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < entriesBlock; i++ {
+			if num == key && i == 0 {
+				continue
+			}
+			pos := 0x04 + i*entrySize
+			e := parseEntry(buf[pos : pos+entrySize])
+			e.block, e.offset = num, pos
+			if !fn(e) {
+				return nil
+			}
+		}
+		num = word(buf[0x02:])
+	}
+	return nil
+}
+
+// addEntry puts the entry into the first free slot of the directory.
+// Subdirectories grow by a block, when full, the volume directory not.
+func (v *Volume) addEntry(dir Entry, e Entry) (Entry, error) {
+	last, found := 0, false
+	err := v.walkDir(dir.Key, func(c Entry) bool {
+		last = c.block
+		if c.Storage == storageDeleted {
+			e.block, e.offset, found = c.block, c.offset, true
+		}
+		return !found
+	})
+	if err != nil {
+		return e, err
+	}
+
+	if !found {
+		if dir.Key == VolumeDir {
+			return e, fmt.Errorf("%s: %w", e.Name, ErrDirFull)
+		}
+		blocks, err := v.allocate(1)
+		if err != nil {
+			return e, fmt.Errorf("%s: %w", e.Name, err)
+		}
+		if err = v.linkBlock(last, blocks[0]); err != nil {
+			return e, err
+		}
+		dir.Blocks++
+		dir.EOF += BlockSize
+		if err = v.updateEntry(dir); err != nil {
+			return e, err
+		}
+		e.block, e.offset = blocks[0], 0x04
+	}
+
+	e.header = dir.Key
+	if err = v.updateEntry(e); err != nil {
+		return e, err
+	}
+	return e, v.countFiles(dir.Key, +1)
+}
+
+// linkBlock appends an empty block to the directory block chain.
+func (v *Volume) linkBlock(last, num int) error {
+	buf, err := v.ReadBlock(last)
+	if err != nil {
+		return err
+	}
+	putWord(buf[0x02:], num)
+	if err = v.WriteBlock(last, buf); err != nil {
+		return err
+	}
+	buf = make([]byte, BlockSize)
+	putWord(buf[0x00:], last)
+	return v.WriteBlock(num, buf)
+}
+
+// updateEntry writes the entry to its location.
+func (v *Volume) updateEntry(e Entry) error {
+	buf, err := v.ReadBlock(e.block)
+	if err != nil {
+		return err
+	}
+	e.marshal(buf[e.offset : e.offset+entrySize])
+	return v.WriteBlock(e.block, buf)
+}
+
+// countFiles changes the file count in the directory header.
+func (v *Volume) countFiles(key int, delta int) error {
+	buf, err := v.ReadBlock(key)
+	if err != nil {
+		return err
+	}
+	putWord(buf[0x04+0x21:], max(0, word(buf[0x04+0x21:])+delta))
+	return v.WriteBlock(key, buf)
+}
+
+// dataBlocks returns the data blocks of a file, zero for sparse blocks.
+func (v *Volume) dataBlocks(e Entry) ([]int, error) {
+	count := (e.EOF + BlockSize - 1) / BlockSize
+
+	switch e.Storage {
+	case storageSeedling:
+		return []int{e.Key}[:min(1, count)], nil
+	case storageSapling:
+		list, err := v.readIndex(e.Key)
+		return list[:min(count, len(list))], err
+	case storageTree:
+		master, err := v.readIndex(e.Key)
+		if err != nil {
+			return nil, err
+		}
+		list := []int{}
+		for _, num := range master[:min((count+0xFF)>>8, len(master))] {
+			if num == 0 {
+				list = append(list, make([]int, 0x100)...)
+				continue
+			}
+			index, err := v.readIndex(num)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, index...)
+		}
+		return list[:min(count, len(list))], nil
+	}
+	return nil, fmt.Errorf("%s: unsupported storage type %d", e.Name, e.Storage)
+}
+
+// fileBlocks returns all blocks in use by a file, index blocks included.
+func (v *Volume) fileBlocks(e Entry) ([]int, error) {
+	list := []int{e.Key}
+
+	switch e.Storage {
+	case storageSapling:
+		index, err := v.readIndex(e.Key)
+		return append(list, index...), err
+	case storageTree:
+		master, err := v.readIndex(e.Key)
+		if err != nil {
+			return nil, err
+		}
+		for _, num := range master {
+			if num == 0 {
+				continue
+			}
+			index, err := v.readIndex(num)
+			if err != nil {
+				return nil, err
+			}
+			list = append(append(list, num), index...)
+		}
+	}
+	return list, nil
+}
+
+// dirBlocks returns the blocks of a directory.
+func (v *Volume) dirBlocks(key int) ([]int, error) {
+	list := []int{}
+	for num := key; num != 0 && len(list) < v.total; {
+		buf, err := v.ReadBlock(num)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, num)
+		num = word(buf[0x02:])
+	}
+	return list, nil
+}
+
+// readIndex returns the 256 block pointers of an index block. The low
+// bytes of the pointers are in the first half, the high bytes in the second.
+func (v *Volume) readIndex(num int) ([]int, error) {
+	buf, err := v.ReadBlock(num)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]int, 0x100)
+	for i := range list {
+		list[i] = int(buf[i]) | int(buf[0x100+i])<<8
+	}
+	return list, nil
+}
+
+func indexBlock(list []int) []byte {
+	buf := make([]byte, BlockSize)
+	for i, num := range list {
+		buf[i], buf[0x100+i] = byte(num), byte(num>>8)
+	}
+	return buf
+}
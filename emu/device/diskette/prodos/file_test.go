@@ -0,0 +1,37 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package prodos
+
+import (
+	"bytes"
+	"retro/emu/device/diskette"
+	"testing"
+)
+
+func TestWriteTreeFile(t *testing.T) {
+	image := diskette.NewBlankImage(diskette.ProDOSOrder)
+	if err := Format(image, "TEST"); err != nil {
+		t.Fatal(err)
+	}
+	v, err := Open(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// More than 256 data blocks, a tree file with two index blocks.
+	data := make([]byte, 0x101*BlockSize+100)
+	for i := range data {
+		data[i] = byte(i*7 + i>>9)
+	}
+	if err = v.WriteFile("", &File{Name: "BIG", Type: TypeBIN, Data: data}); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := v.ReadFile("BIG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.Data, data) {
+		t.Errorf("read %d bytes, differ from the %d written", len(f.Data), len(data))
+	}
+}
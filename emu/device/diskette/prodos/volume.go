@@ -0,0 +1,214 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package prodos
+
+import (
+	"errors"
+	"fmt"
+	"retro/emu/device/diskette"
+	"time"
+)
+
+type (
+	// Volume is a ProDOS file system on a 16 sector image.
+	Volume struct {
+		image  *diskette.Image
+		name   string
+		total  int
+		bitmap int
+	}
+)
+
+var (
+	// ErrNoProDOS is returned, when the image has no ProDOS file system.
+	ErrNoProDOS = errors.New("no ProDOS file system")
+
+	// ErrBroken is returned for pointers leaving the volume or looping.
+	ErrBroken = errors.New("broken block chain")
+
+	// ErrDiskFull is returned, when there are not enough free blocks.
+	ErrDiskFull = errors.New("disk full")
+
+	// ErrDirFull is returned, when the volume directory has no free entry.
+	ErrDirFull = errors.New("directory full")
+
+	// ErrLocked is returned, when a locked file would be replaced.
+	ErrLocked = errors.New("file locked")
+
+	// ErrNotFound is returned, when a path does not exist.
+	ErrNotFound = errors.New("file not found")
+)
+
+const (
+	// BlockSize is the size of a ProDOS block.
+	BlockSize = 0x200
+
+	// Blocks is the number of blocks of a 140K disk.
+	Blocks = 35 * 8
+
+	// VolumeDir is the key block of the volume directory.
+	VolumeDir = 2
+)
+
+// Open reads the volume directory header of a ProDOS file system.
+// The image may be stored in ProDOS or DOS order.
+func Open(image *diskette.Image) (*Volume, error) {
+	v := &Volume{image: image}
+
+	buf, err := v.ReadBlock(VolumeDir)
+	if err != nil {
+		return nil, err
+	}
+	h := buf[0x04:]
+	if buf[0x00] != 0 || buf[0x01] != 0 || h[0x00]>>4 != storageVolume || h[0x1F] != entrySize {
+		return nil, ErrNoProDOS
+	}
+
+	v.name = string(h[0x01 : 0x01+h[0x00]&0x0F])
+	v.bitmap = word(h[0x23:])
+	v.total = min(word(h[0x25:]), Blocks)
+	return v, nil
+}
+
+// Format writes an empty ProDOS file system to the image: boot blocks
+// 0 and 1 (left empty, not bootable), the volume directory in the blocks
+// 2 to 5 and the volume bitmap in block 6.
+func Format(image *diskette.Image, name string) error {
+	name, err := checkName(name)
+	if err != nil {
+		return err
+	}
+	v := &Volume{image: image, name: name, total: Blocks, bitmap: 6}
+
+	for num := VolumeDir; num < v.bitmap; num++ {
+		buf := make([]byte, BlockSize)
+		if num > VolumeDir {
+			putWord(buf[0x00:], num-1)
+		}
+		if num < v.bitmap-1 {
+			putWord(buf[0x02:], num+1)
+		}
+		if num == VolumeDir {
+			h := buf[0x04:]
+			h[0x00] = storageVolume<<4 | byte(len(name))
+			copy(h[0x01:0x10], name)
+			putTime(h[0x18:], time.Now())
+			h[0x1E] = accessUnlocked
+			h[0x1F] = entrySize
+			h[0x20] = entriesBlock
+			putWord(h[0x23:], v.bitmap)
+			putWord(h[0x25:], v.total)
+		}
+		if err = v.WriteBlock(num, buf); err != nil {
+			return err
+		}
+	}
+
+	bitmap := make([]byte, BlockSize)
+	for num := v.bitmap + 1; num < v.total; num++ {
+		bitmap[num>>3] |= 0x80 >> (num & 0x07)
+	}
+	return v.writeBitmap(bitmap)
+}
+
+// Name returns the name of the volume.
+func (v *Volume) Name() string {
+	return v.name
+}
+
+// ReadBlock returns a copy of a 512 byte block.
+func (v *Volume) ReadBlock(num int) ([]byte, error) {
+	if num < 0 || num >= Blocks {
+		return nil, fmt.Errorf("block %d: %w", num, ErrBroken)
+	}
+	trk, sec := num>>3, (num&0x07)<<1
+
+	lo, err := v.image.ReadSector(trk, sec, diskette.ProDOSOrder)
+	if err != nil {
+		return nil, err
+	}
+	hi, err := v.image.ReadSector(trk, sec+1, diskette.ProDOSOrder)
+	if err != nil {
+		return nil, err
+	}
+	return append(lo, hi...), nil
+}
+
+// WriteBlock replaces a 512 byte block.
+func (v *Volume) WriteBlock(num int, buf []byte) error {
+	if num < 0 || num >= Blocks {
+		return fmt.Errorf("block %d: %w", num, ErrBroken)
+	}
+	trk, sec := num>>3, (num&0x07)<<1
+
+	if err := v.image.WriteSector(trk, sec, diskette.ProDOSOrder, buf[:0x100]); err != nil {
+		return err
+	}
+	return v.image.WriteSector(trk, sec+1, diskette.ProDOSOrder, buf[0x100:])
+}
+
+// Free returns the number of free blocks.
+func (v *Volume) Free() (int, error) {
+	bitmap, err := v.readBitmap()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for num := 0; num < v.total; num++ {
+		if bitmap[num>>3]&(0x80>>(num&0x07)) != 0 {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// allocate finds count free blocks and marks them as used.
+func (v *Volume) allocate(count int) ([]int, error) {
+	bitmap, err := v.readBitmap()
+	if err != nil {
+		return nil, err
+	}
+	list := []int{}
+	for num := 0; num < v.total && len(list) < count; num++ {
+		if bitmap[num>>3]&(0x80>>(num&0x07)) != 0 {
+			bitmap[num>>3] &^= 0x80 >> (num & 0x07)
+			list = append(list, num)
+		}
+	}
+	if len(list) < count {
+		return nil, ErrDiskFull
+	}
+	return list, v.writeBitmap(bitmap)
+}
+
+// release marks blocks as free.
+func (v *Volume) release(list []int) error {
+	bitmap, err := v.readBitmap()
+	if err != nil {
+		return err
+	}
+	for _, num := range list {
+		if num > 0 && num < v.total {
+			bitmap[num>>3] |= 0x80 >> (num & 0x07)
+		}
+	}
+	return v.writeBitmap(bitmap)
+}
+
+// The volume bitmap has one bit per block, a set bit is a free block.
+// A bitmap block covers 4096 blocks, a 140K volume needs one.
+func (v *Volume) readBitmap() ([]byte, error) {
+	return v.ReadBlock(v.bitmap)
+}
+
+func (v *Volume) writeBitmap(bitmap []byte) error {
+	return v.WriteBlock(v.bitmap, bitmap)
+}
+
+func word(b []byte) int {
+	return int(b[0]) | int(b[1])<<8
+}
+
+func putWord(b []byte, w int) {
+	b[0], b[1] = byte(w), byte(w>>8)
+}
@@ -2,31 +2,145 @@
 
 package diskette
 
+import (
+	"math/rand"
+)
+
 type (
-	// Reader is an endless stream reader.
-	Reader interface {
-		Read() byte
+	// BitReader provides a track as an endless stream of bits. A track
+	// is not necessarily a multiple of 8 bits, as found in WOZ images.
+	BitReader struct {
+		buf   []byte
+		count int
+		pos   int
+		dirty bool
 	}
+)
 
-	// TrackReader provides track data.
-	TrackReader struct {
-		buf []byte
-		pos int
+// NewBitReader creates a new bit stream reader over count bits of buf.
+func NewBitReader(buf []byte, count int) *BitReader {
+	if count > len(buf)<<3 {
+		count = len(buf) << 3
 	}
-)
+	return &BitReader{buf: buf, count: count}
+}
 
-// NewTrackReader creates a new track reader.
-func NewTrackReader(buf []byte) *TrackReader {
-	return &TrackReader{buf, 0}
+// NewNibbleReader creates a bit stream reader from a nibble stream.
+// A sync byte (0xFF next to another 0xFF) is followed by two zero bits,
+// so the disk controller gets in sync, wherever it starts reading.
+func NewNibbleReader(nibbles []byte) *BitReader {
+	n := len(nibbles)
+	r := &BitReader{buf: make([]byte, n*10/8+1)}
+	r.count = len(r.buf) << 3
+
+	for i, b := range nibbles {
+		r.Write(b)
+		if b == 0xFF && (nibbles[(i+1)%n] == 0xFF || nibbles[(i+n-1)%n] == 0xFF) {
+			r.setBit(0)
+			r.setBit(0)
+		}
+	}
+	r.count, r.pos, r.dirty = r.pos, 0, false
+	return r
 }
 
-// Reads a byte from the track stream. When the track reader has reached
-// the end of its buffer, it starts again from the beginning, simulating
-// an endless stream of bits (here as bytes) from a diskette.
-func (r *TrackReader) Read() byte {
-	b := r.buf[r.pos]
-	if r.pos++; r.pos == len(r.buf) {
-		r.pos = 0
+// NewNoiseReader creates a bit stream of random bits, as the drive
+// picks up from an unformatted disk or without a disk.
+func NewNoiseReader(size int) *BitReader {
+	buf := make([]byte, size)
+	_, _ = rand.New(rand.NewSource(0x445447)).Read(buf)
+	return NewBitReader(buf, size<<3)
+}
+
+// Read shifts bits from the track stream into a latch, until the most
+// significant bit of the latch is set, like the disk controller does.
+// Leading zero bits do not count. After a full revolution without a
+// one bit, the empty latch is returned.
+func (r *BitReader) Read() byte {
+	latch := byte(0)
+	for i := 0; i < r.count; i++ {
+		if latch = latch<<1 | r.bit(); latch&0x80 != 0 {
+			break
+		}
+	}
+	return latch
+}
+
+// Write writes the bits of a byte to the track stream at the current
+// position, most significant bit first, overwriting the former content.
+func (r *BitReader) Write(b byte) {
+	for i := 7; i >= 0; i-- {
+		r.setBit(b >> i & 0x01)
+	}
+}
+
+// Dirty signals, whether the track has been written to.
+func (r *BitReader) Dirty() bool {
+	return r.dirty
+}
+
+// nibbles returns the nibbles of one revolution, as the disk controller
+// reads them. The revolution before is used to get in sync.
+func (r *BitReader) nibbles() []byte {
+	s := &BitReader{buf: r.buf, count: r.count}
+	read := func() (byte, int) {
+		from := s.pos
+		b := s.Read()
+		if n := (s.pos - from + s.count) % s.count; n > 0 {
+			return b, n
+		}
+		return b, s.count
 	}
+
+	for bits := 0; bits < s.count; {
+		b, n := read()
+		if b == 0 {
+			return nil
+		}
+		bits += n
+	}
+
+	buf := make([]byte, 0, s.count>>3)
+	for bits := 0; bits < s.count; {
+		b, n := read()
+		buf = append(buf, b)
+		bits += n
+	}
+	return buf
+}
+
+func (r *BitReader) bit() byte {
+	if r.count == 0 {
+		return 0
+	}
+	b := r.buf[r.pos>>3] >> (7 - r.pos&0x07) & 0x01
+	r.advance()
 	return b
 }
+
+func (r *BitReader) setBit(b byte) {
+	if r.count == 0 {
+		return
+	}
+	mask := byte(0x80) >> (r.pos & 0x07)
+	if b != 0 {
+		r.buf[r.pos>>3] |= mask
+	} else {
+		r.buf[r.pos>>3] &^= mask
+	}
+	r.dirty = true
+	r.advance()
+}
+
+func (r *BitReader) advance() {
+	if r.pos++; r.pos == r.count {
+		r.pos = 0
+	}
+}
+
+// skip advances the stream by n bits.
+func (r *BitReader) skip(n uint64) {
+	if r.count > 0 {
+		r.pos = int((uint64(r.pos) + n) % uint64(r.count))
+	}
+}
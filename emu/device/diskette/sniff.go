@@ -0,0 +1,79 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package diskette
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrFormat is returned, when the data is not a disk image.
+	ErrFormat = errors.New("not a disk image")
+)
+
+// Format2IMG is a 2IMG container (.2mg) with sector or nibble data.
+const Format2IMG Format = "2mg"
+
+// Sniff detects the format of a disk image by magic, size and content,
+// rejecting anything else with a specific error. The name of the image
+// helps to diagnose truncated images only.
+func Sniff(name string, data []byte) (Format, error) {
+	switch {
+	case len(data) == 0:
+		return "", fmt.Errorf("%w: empty file", ErrFormat)
+	case bytes.HasPrefix(data, []byte("WOZ1")), bytes.HasPrefix(data, []byte("WOZ2")):
+		return FormatWOZ, nil
+	case bytes.HasPrefix(data, twoIMGMagic):
+		return Format2IMG, nil
+	case len(data) == ImageSize, len(data) == Image40Size:
+		return FormatDSK, nil
+	case len(data) == Image13Size:
+		return FormatD13, nil
+	case len(data) == NibbleImageSize:
+		if !isNibbles(data) {
+			return "", fmt.Errorf("%w: nibble image size, but invalid disk bytes", ErrFormat)
+		}
+		return FormatNIB, nil
+	case isText(data):
+		return "", fmt.Errorf("%w: text file", ErrFormat)
+	}
+
+	size := map[string]int{
+		".dsk": ImageSize, ".do": ImageSize, ".po": ImageSize,
+		".d13": Image13Size, ".nib": NibbleImageSize,
+	}[strings.ToLower(filepath.Ext(name))]
+
+	switch {
+	case size > 0 && len(data) < size:
+		return "", fmt.Errorf("%w: truncated, %d of %d bytes", ErrFormat, len(data), size)
+	case size > 0:
+		return "", fmt.Errorf("%w: %d bytes, expected %d", ErrFormat, len(data), size)
+	}
+	return "", fmt.Errorf("%w: unknown format, %d bytes", ErrFormat, len(data))
+}
+
+// isNibbles signals, whether the data is mostly made of disk bytes,
+// which have the most significant bit set.
+func isNibbles(data []byte) bool {
+	count := 0
+	for _, b := range data {
+		if b&0x80 == 0 {
+			count++
+		}
+	}
+	return count < len(data)/10
+}
+
+// isText signals, whether the start of the data is printable ASCII.
+func isText(data []byte) bool {
+	for _, b := range data[:min(len(data), 0x200)] {
+		if (b < 0x20 || b > 0x7E) && b != '\t' && b != '\n' && b != '\r' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,171 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package diskette
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// See https://apple2.org.za/gswv/a2zine/Docs/DiskImage_2MG_Info.txt
+// for the 2IMG format.
+
+type (
+	// twoIMG is the 2IMG container of a sector or nibble image. The
+	// header and the chunks following the image data are preserved.
+	twoIMG struct {
+		header  []byte
+		comment []byte
+		creator []byte
+	}
+)
+
+var (
+	// ErrTwoIMG is returned for malformed 2IMG images.
+	ErrTwoIMG = errors.New("malformed 2IMG image")
+
+	twoIMGMagic = []byte("2IMG")
+)
+
+const (
+	twoIMGHeaderSize = 64
+
+	// Image data formats.
+	twoIMGDOS    = 0
+	twoIMGProDOS = 1
+	twoIMGNIB    = 2
+
+	// Flags.
+	twoIMGLocked      = 0x80000000
+	twoIMGVolumeValid = 0x00000100
+)
+
+// Load2IMG loads a 2IMG (.2mg) image. The header determines the sector
+// order (DOS, ProDOS) or whether the image data is a nibble image.
+// The image is written back with the header, comment and creator chunks.
+func (im *Image) Load2IMG(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(data) < twoIMGHeaderSize || !bytes.HasPrefix(data, twoIMGMagic) {
+		return fmt.Errorf("%w: invalid header", ErrTwoIMG)
+	}
+	le := binary.LittleEndian
+
+	size := int(le.Uint16(data[0x08:]))
+	format := le.Uint32(data[0x0C:])
+	flags := le.Uint32(data[0x10:])
+
+	if size < twoIMGHeaderSize || size > len(data) {
+		return fmt.Errorf("%w: header size %d", ErrTwoIMG, size)
+	}
+	chunk := func(at int) ([]byte, error) {
+		from, length := int(le.Uint32(data[at:])), int(le.Uint32(data[at+4:]))
+		if length == 0 {
+			return nil, nil
+		}
+		if from < size || from+length > len(data) {
+			return nil, fmt.Errorf("%w: chunk at offset %d exceeds file size", ErrTwoIMG, from)
+		}
+		return data[from : from+length], nil
+	}
+
+	payload, err := chunk(0x18)
+	if err != nil {
+		return err
+	}
+	comment, err := chunk(0x20)
+	if err != nil {
+		return err
+	}
+	creator, err := chunk(0x28)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case twoIMGDOS, twoIMGProDOS:
+		if len(payload) != ImageSize {
+			return fmt.Errorf("%w: image data size %d, expected %d", ErrTwoIMG, len(payload), ImageSize)
+		}
+		order := DOSOrder
+		if format == twoIMGProDOS {
+			order = ProDOSOrder
+		}
+		im.encoder, im.decoder = NewEncoder(order), NewDecoder(order)
+		err = im.Load(bytes.NewReader(payload))
+	case twoIMGNIB:
+		err = im.LoadNIB(bytes.NewReader(payload))
+	default:
+		return fmt.Errorf("%w: unknown image format %d", ErrTwoIMG, format)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The volume number of the header takes precedence over the VTOC.
+	if flags&twoIMGVolumeValid != 0 && format != twoIMGNIB {
+		im.encoder.SetVolume(byte(flags))
+		im.encodeTracks()
+	}
+
+	im.container = &twoIMG{
+		header:  append([]byte{}, data[:size]...),
+		comment: append([]byte{}, comment...),
+		creator: append([]byte{}, creator...),
+	}
+
+	im.meta = map[string]string{
+		"creator": strings.TrimRight(string(data[0x04:0x08]), "\x00 "),
+	}
+	if len(comment) > 0 {
+		im.meta["comment"] = strings.TrimRight(string(comment), "\x00")
+	}
+	if flags&twoIMGVolumeValid != 0 {
+		im.meta["volume"] = fmt.Sprint(flags & 0xFF)
+	}
+	if flags&twoIMGLocked != 0 {
+		im.meta["write_protected"] = "1"
+	}
+	return nil
+}
+
+// write writes the header, the image data written by fn and the
+// comment and creator chunks. The chunks are laid out anew, in order.
+func (c *twoIMG) write(w io.Writer, fn func(w io.Writer) (int64, error)) (int64, error) {
+	buf := &bytes.Buffer{}
+	_, lost := fn(buf)
+	if lost != nil && !errors.Is(lost, ErrTrack) {
+		return 0, lost
+	}
+	le := binary.LittleEndian
+
+	header := append([]byte{}, c.header...)
+	pos := len(header)
+	for _, chunk := range []struct {
+		at   int
+		data []byte
+	}{{0x18, buf.Bytes()}, {0x20, c.comment}, {0x28, c.creator}} {
+		if len(chunk.data) == 0 {
+			le.PutUint64(header[chunk.at:], 0)
+			continue
+		}
+		le.PutUint32(header[chunk.at:], uint32(pos))
+		le.PutUint32(header[chunk.at+4:], uint32(len(chunk.data)))
+		pos += len(chunk.data)
+	}
+
+	total := int64(0)
+	for _, b := range [][]byte{header, buf.Bytes(), c.comment, c.creator} {
+		num, err := w.Write(b)
+		if total += int64(num); err != nil {
+			return total, err
+		}
+	}
+	return total, lost
+}
@@ -0,0 +1,210 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package diskette
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+// See https://applesaucefdc.com/woz/reference2/ for the WOZ format.
+
+type (
+	// wozInfo is the content of the WOZ INFO chunk.
+	wozInfo struct {
+		version   byte
+		diskType  byte
+		protected bool
+		creator   string
+		timing    byte
+	}
+)
+
+var (
+	// ErrWOZ is returned for malformed WOZ images.
+	ErrWOZ = errors.New("malformed WOZ image")
+
+	wozMagic = []byte{0xFF, 0x0A, 0x0D, 0x0A}
+)
+
+const (
+	wozHeaderSize   = 12
+	wozInfoSize     = 60
+	wozTMapSize     = 160
+	wozTrackSize1   = 6656 // WOZ1 TRK size, including trailer.
+	wozTrackBytes1  = 6646 // WOZ1 bit stream size.
+	wozTrackSize2   = 8    // WOZ2 TRK entry size.
+	wozBlockSize2   = 512
+	wozDiskType5_25 = 1
+)
+
+// LoadWOZ loads a WOZ 1.0 or 2.0 disk image and prepares
+// bit stream readers for all mapped quarter-tracks.
+func (im *Image) LoadWOZ(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if len(data) < wozHeaderSize {
+		return fmt.Errorf("%w: header too short", ErrWOZ)
+	}
+	ver := string(data[0:4])
+	if (ver != "WOZ1" && ver != "WOZ2") || !bytes.Equal(data[4:8], wozMagic) {
+		return fmt.Errorf("%w: invalid header", ErrWOZ)
+	}
+
+	// A zero CRC means: not calculated.
+	if crc := binary.LittleEndian.Uint32(data[8:12]); crc != 0 {
+		if sum := crc32.ChecksumIEEE(data[wozHeaderSize:]); sum != crc {
+			return fmt.Errorf("%w: CRC 0x%08X, expected 0x%08X", ErrWOZ, sum, crc)
+		}
+	}
+
+	chunks, err := wozChunks(data[wozHeaderSize:])
+	if err != nil {
+		return err
+	}
+
+	info, err := wozParseInfo(chunks["INFO"])
+	if err != nil {
+		return err
+	}
+	if info.diskType != wozDiskType5_25 {
+		return fmt.Errorf("%w: disk type %d is not a 5.25 inch disk", ErrWOZ, info.diskType)
+	}
+
+	tmap := chunks["TMAP"]
+	if len(tmap) < wozTMapSize {
+		return fmt.Errorf("%w: TMAP chunk missing or too short", ErrWOZ)
+	}
+	trks, ok := chunks["TRKS"]
+	if !ok {
+		return fmt.Errorf("%w: TRKS chunk missing", ErrWOZ)
+	}
+
+	// Quarter-tracks mapping to the same track share their reader.
+	readers := map[byte]*BitReader{}
+
+	for q := 0; q < wozTMapSize && q < len(im.readers); q++ {
+		num := tmap[q]
+		if num == 0xFF {
+			im.readers[q] = nil
+			continue
+		}
+		if _, ok := readers[num]; !ok {
+			var reader *BitReader
+			if ver == "WOZ1" {
+				reader, err = wozTrack1(trks, num)
+			} else {
+				reader, err = wozTrack2(data, trks, num)
+			}
+			if err != nil {
+				return err
+			}
+			readers[num] = reader
+		}
+		im.readers[q] = readers[num]
+	}
+
+	if info.timing > 0 {
+		im.timing = uint64(info.timing)
+	}
+	im.meta = wozParseMeta(chunks["META"])
+	im.meta["creator"] = info.creator
+	if info.protected {
+		im.meta["write_protected"] = "1"
+	}
+	im.format = FormatWOZ
+	return nil
+}
+
+// wozChunks splits the chunks following the header by id.
+func wozChunks(data []byte) (map[string][]byte, error) {
+	chunks := map[string][]byte{}
+
+	for pos := 0; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+
+		if pos += 8; size < 0 || pos+size > len(data) {
+			return nil, fmt.Errorf("%w: %s chunk exceeds file size", ErrWOZ, id)
+		}
+		chunks[id] = data[pos : pos+size]
+		pos += size
+	}
+	return chunks, nil
+}
+
+// wozParseInfo parses the INFO chunk.
+func wozParseInfo(data []byte) (wozInfo, error) {
+	if len(data) < wozInfoSize {
+		return wozInfo{}, fmt.Errorf("%w: INFO chunk missing or too short", ErrWOZ)
+	}
+	info := wozInfo{
+		version:   data[0],
+		diskType:  data[1],
+		protected: data[2] == 1,
+		creator:   strings.TrimSpace(string(data[5:37])),
+	}
+	// Optimal bit timing, since INFO version 2.
+	if info.version >= 2 {
+		info.timing = data[39]
+	}
+	return info, nil
+}
+
+// wozParseMeta parses the tab separated key/value lines of a META chunk.
+func wozParseMeta(data []byte) map[string]string {
+	meta := map[string]string{}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if key, val, ok := strings.Cut(line, "\t"); ok {
+			meta[key] = val
+		}
+	}
+	return meta
+}
+
+// wozTrack1 creates a reader for a WOZ1 TRKS entry.
+func wozTrack1(trks []byte, num byte) (*BitReader, error) {
+	pos := int(num) * wozTrackSize1
+	if pos+wozTrackSize1 > len(trks) {
+		return nil, fmt.Errorf("%w: track %d missing in TRKS chunk", ErrWOZ, num)
+	}
+	trk := trks[pos : pos+wozTrackSize1]
+	count := int(binary.LittleEndian.Uint16(trk[wozTrackBytes1+2:]))
+
+	if count == 0 || count > wozTrackBytes1<<3 {
+		return nil, fmt.Errorf("%w: track %d has invalid bit count %d", ErrWOZ, num, count)
+	}
+	return NewBitReader(trk[:wozTrackBytes1], count), nil
+}
+
+// wozTrack2 creates a reader for a WOZ2 TRK entry and its bit blocks.
+func wozTrack2(data []byte, trks []byte, num byte) (*BitReader, error) {
+	pos := int(num) * wozTrackSize2
+	if pos+wozTrackSize2 > len(trks) {
+		return nil, fmt.Errorf("%w: track %d missing in TRKS chunk", ErrWOZ, num)
+	}
+	trk := trks[pos : pos+wozTrackSize2]
+	block := int(binary.LittleEndian.Uint16(trk[0:]))
+	blocks := int(binary.LittleEndian.Uint16(trk[2:]))
+	count := int(binary.LittleEndian.Uint32(trk[4:]))
+
+	from := block * wozBlockSize2
+	till := from + blocks*wozBlockSize2
+
+	if blocks == 0 || till > len(data) {
+		return nil, fmt.Errorf("%w: track %d exceeds file size", ErrWOZ, num)
+	}
+	if count == 0 || count > (till-from)<<3 {
+		return nil, fmt.Errorf("%w: track %d has invalid bit count %d", ErrWOZ, num, count)
+	}
+	return NewBitReader(data[from:till], count), nil
+}
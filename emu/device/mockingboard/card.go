@@ -0,0 +1,151 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package mockingboard
+
+import (
+	"retro/emu/device/builtin"
+	"retro/emu/memory"
+)
+
+type (
+	// Card is a Mockingboard sound card with two 6522 VIAs, each one
+	// driving an AY-3-8910 PSG. The first one plays on the left, the
+	// second one on the right channel. The VIAs are accessed at 0xCn00
+	// and 0xCn80, their timers request interrupts.
+	Card struct {
+		via   [2]*VIA
+		psg   [2]*PSG
+		slot  byte
+		clock memory.Clock
+		sink  builtin.Sink
+		step  float64    // CPU cycles per sample
+		next  float64    // CPU cycle of the next sample boundary
+		at    uint64     // CPU cycle, the PSGs have been run to
+		acc   [2]float64 // summed PSG output of the current sample
+		ticks int        // PSG ticks of the current sample
+		in    [2]float64 // DC blocker input state
+		out   [2]float64 // DC blocker output state
+		buf   []int16
+	}
+)
+
+const (
+	// psgTick is the number of CPU cycles per PSG tick.
+	psgTick = 8
+
+	// cardBlock is the number of frames passed to the sink at once.
+	cardBlock = 0x200
+
+	// cardVolume scales the PSG output (0-3) to the 16 bit sample range.
+	cardVolume = 0x1000
+
+	// dcBlock is the pole of the DC blocker, the PSG output is unipolar.
+	dcBlock = 0.995
+)
+
+// NewCard creates a Mockingboard. The CPU runs at hz cycles per second,
+// the sink receives rate frames per second. Without a sink, it is silent.
+func NewCard(clock memory.Clock, hz int, rate int, sink builtin.Sink) *Card {
+	c := &Card{
+		psg:   [2]*PSG{NewPSG(), NewPSG()},
+		clock: clock,
+		sink:  sink,
+		step:  float64(hz) / float64(rate),
+		buf:   make([]int16, 0, cardBlock<<1),
+	}
+	c.via = [2]*VIA{NewVIA(clock, c.psg[0]), NewVIA(clock, c.psg[1])}
+	c.at = clock.Cycles()
+	c.next = float64(c.at) + c.step
+	return c
+}
+
+// Read reads a byte, if this device is sensitive to this address.
+func (c *Card) Read(lo, hi byte) (byte, bool) {
+	if c.slot == 0 || hi != 0xC0|c.slot {
+		return 0, false
+	}
+	return c.via[lo>>7].Read(lo & 0x0F), true
+}
+
+// Write writes a byte, if this device is sensitive to this address.
+// The PSGs are run up to now first, the change takes effect from now on.
+func (c *Card) Write(lo, hi, b byte) bool {
+	if c.slot == 0 || hi != 0xC0|c.slot {
+		return false
+	}
+	c.advance(c.clock.Cycles())
+	c.via[lo>>7].Write(lo&0x0F, b)
+	return true
+}
+
+// IRQ signals, whether a VIA requests an interrupt.
+func (c *Card) IRQ() bool {
+	if c.slot == 0 {
+		return false
+	}
+	return c.via[0].IRQ() || c.via[1].IRQ()
+}
+
+// Flush renders the samples up to the current CPU cycle and passes
+// them to the sink. It is called after each batch of CPU steps.
+func (c *Card) Flush() {
+	if c.sink == nil {
+		return
+	}
+	c.advance(c.clock.Cycles())
+	if len(c.buf) > 0 {
+		c.sink.Play(c.buf)
+		c.buf = c.buf[:0]
+	}
+}
+
+// Reset resets the VIAs and the PSGs.
+func (c *Card) Reset() {
+	c.via[0].Reset()
+	c.via[1].Reset()
+}
+
+// Slot is set by the memory Manager, depending on where this device was mounted.
+func (c *Card) Slot(num byte) {
+	c.slot = num & 0x07
+}
+
+// advance runs the PSGs up to the CPU cycle. Each sample is the average
+// output of the PSG ticks within its interval.
+func (c *Card) advance(now uint64) {
+	if c.sink == nil {
+		return
+	}
+	for ; c.at+psgTick <= now; c.at += psgTick {
+		c.acc[0] += c.psg[0].Tick()
+		c.acc[1] += c.psg[1].Tick()
+		c.ticks++
+
+		for float64(c.at+psgTick) >= c.next {
+			c.emit()
+			c.next += c.step
+		}
+	}
+}
+
+// emit filters a frame and buffers it for the sink. Without PSG ticks
+// in the interval (at a very low CPU clock), the former value is kept.
+func (c *Card) emit() {
+	for i := range c.acc {
+		v := c.in[i]
+		if c.ticks > 0 {
+			v = c.acc[i] / float64(c.ticks)
+		}
+		c.out[i] = v - c.in[i] + dcBlock*c.out[i]
+		c.in[i] = v
+		c.acc[i] = 0
+
+		c.buf = append(c.buf, int16(max(-2, min(2, c.out[i]))*cardVolume))
+	}
+	c.ticks = 0
+
+	if len(c.buf) == cap(c.buf) {
+		c.sink.Play(c.buf)
+		c.buf = c.buf[:0]
+	}
+}
@@ -0,0 +1,175 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package mockingboard
+
+import (
+	"math"
+)
+
+type (
+	// PSG is a General Instrument AY-3-8910 Programmable Sound Generator
+	// with three square wave tone channels, a noise generator and an
+	// envelope generator. It runs at the CPU clock, Tick advances it by
+	// eight clock cycles, the base unit of its counters.
+	PSG struct {
+		reg   [0x10]byte
+		addr  byte // latched register address
+		read  bool // the PSG drives the data bus
+		count [3]uint16
+		tone  [3]bool
+		noise struct {
+			count uint16
+			rng   uint32 // 17 bit shift register
+		}
+		env struct {
+			count   uint32
+			step    byte // 15 down to 0
+			attack  byte // 0x00 or 0x0F, inverts the step
+			hold    bool // hold the level after the first cycle
+			alt     bool // alternate the direction
+			holding bool
+		}
+	}
+)
+
+// Register write masks, unused bits read back as zero.
+var psgMasks = [0x10]byte{
+	0xFF, 0x0F, 0xFF, 0x0F, 0xFF, 0x0F, 0x1F, 0xFF,
+	0x1F, 0x1F, 0x1F, 0xFF, 0xFF, 0x0F, 0xFF, 0xFF,
+}
+
+// psgLevels are the amplitudes of the 16 volume levels, 3 dB apart.
+var psgLevels = func() (levels [0x10]float64) {
+	for i := 1; i < 0x10; i++ {
+		levels[i] = math.Pow(2, -float64(0x0F-i)/2)
+	}
+	return levels
+}()
+
+// NewPSG creates a PSG.
+func NewPSG() *PSG {
+	p := &PSG{}
+	p.Reset()
+	return p
+}
+
+// Control executes the bus function selected by the control lines BC1
+// (bit 0), BDIR (bit 1) and /RESET (bit 2) with the data bus value.
+func (p *PSG) Control(lines, data byte) {
+	p.read = false
+
+	switch lines & 0x07 {
+	case 0x00, 0x01, 0x02, 0x03: // RESET
+		p.Reset()
+	case 0x05: // READ
+		p.read = true
+	case 0x06: // WRITE
+		p.write(data)
+	case 0x07: // LATCH ADDRESS
+		p.addr = data
+	}
+}
+
+// Bus returns the register value in read mode, or an idle data bus.
+func (p *PSG) Bus() byte {
+	if p.read && p.addr < 0x10 {
+		return p.reg[p.addr]
+	}
+	return 0xFF
+}
+
+// Reset clears the registers, the PSG is silent.
+func (p *PSG) Reset() {
+	p.reg = [0x10]byte{}
+	p.addr, p.read = 0, false
+	p.noise.rng = 1
+	p.restart()
+}
+
+func (p *PSG) write(b byte) {
+	if p.addr >= 0x10 {
+		return
+	}
+	p.reg[p.addr] = b & psgMasks[p.addr]
+
+	// Writing the shape restarts the envelope.
+	if p.addr == 0x0D {
+		p.restart()
+	}
+}
+
+// restart starts the envelope according to the shape: CONTINUE (bit 3),
+// ATTACK (bit 2), ALTERNATE (bit 1), HOLD (bit 0). Without CONTINUE,
+// the level drops to zero after the first cycle and holds there.
+func (p *PSG) restart() {
+	shape := p.reg[0x0D]
+	e := &p.env
+
+	e.count, e.step, e.holding = 0, 0x0F, false
+	e.attack = 0x00
+	if shape&0x04 != 0 {
+		e.attack = 0x0F
+	}
+	if shape&0x08 == 0 {
+		e.hold, e.alt = true, e.attack != 0
+	} else {
+		e.hold, e.alt = shape&0x01 != 0, shape&0x02 != 0
+	}
+}
+
+// Tick advances the PSG by eight clock cycles and returns the sum of
+// the channel amplitudes (0-3). A tone channel flips every period ticks,
+// the noise and the envelope generator step every two period ticks.
+func (p *PSG) Tick() float64 {
+	for i := range p.count {
+		period := max(1, uint16(p.reg[i<<1])|uint16(p.reg[i<<1+1])<<8)
+		if p.count[i]++; p.count[i] >= period {
+			p.count[i], p.tone[i] = 0, !p.tone[i]
+		}
+	}
+
+	n := &p.noise
+	if n.count++; n.count >= max(1, uint16(p.reg[0x06]))<<1 {
+		n.count = 0
+		n.rng = n.rng>>1 | ((n.rng^n.rng>>3)&1)<<16
+	}
+
+	e := &p.env
+	if e.count++; e.count >= max(1, uint32(p.reg[0x0B])|uint32(p.reg[0x0C])<<8)<<1 {
+		e.count = 0
+		p.envelope()
+	}
+
+	// Mixer, the enable bits are active low.
+	out, mix, noise := 0.0, p.reg[0x07], n.rng&1 != 0
+	for i := range p.tone {
+		if (p.tone[i] || mix&(0x01<<i) != 0) && (noise || mix&(0x08<<i) != 0) {
+			if level := p.reg[0x08+i]; level&0x10 != 0 {
+				out += psgLevels[e.step^e.attack]
+			} else {
+				out += psgLevels[level]
+			}
+		}
+	}
+	return out
+}
+
+// envelope steps the envelope generator.
+func (p *PSG) envelope() {
+	e := &p.env
+	if e.holding {
+		return
+	}
+	if e.step > 0 {
+		e.step--
+		return
+	}
+	if e.alt {
+		e.attack ^= 0x0F
+	}
+	if e.hold {
+		e.holding = true
+		return
+	}
+	e.step = 0x0F
+}
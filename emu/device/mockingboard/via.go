@@ -0,0 +1,198 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package mockingboard
+
+import (
+	"retro/emu/memory"
+)
+
+type (
+	// VIA is a MOS 6522 Versatile Interface Adapter. Port A is the data
+	// bus of the PSG, bits 0-2 of port B are its control lines. Timer 1
+	// (one-shot or free-running) and timer 2 (one-shot) request interrupts,
+	// the shift register and the handshake lines are not wired.
+	VIA struct {
+		clock memory.Clock
+		psg   *PSG
+		last  uint64 // CPU cycle, the timers have been run to
+		orb   byte
+		ora   byte
+		ddrb  byte
+		ddra  byte
+		t1    uint16 // timer 1 counter
+		t1l   uint16 // timer 1 latch
+		t2    uint16 // timer 2 counter
+		t2l   byte   // timer 2 latch, low byte
+		t1on  bool   // timer 1 interrupt armed
+		t2on  bool   // timer 2 interrupt armed
+		sr    byte
+		acr   byte
+		pcr   byte
+		ifr   byte
+		ier   byte
+	}
+)
+
+// Interrupt flags of IFR and IER.
+const (
+	irqT2  = 0x20
+	irqT1  = 0x40
+	irqAny = 0x80
+)
+
+// NewVIA creates a VIA driving the PSG. The clock drives the timers.
+func NewVIA(clock memory.Clock, psg *PSG) *VIA {
+	v := &VIA{clock: clock, psg: psg}
+	v.Reset()
+	return v
+}
+
+// Read reads a register (0x00-0x0F).
+func (v *VIA) Read(reg byte) byte {
+	v.run(v.clock.Cycles())
+
+	switch reg & 0x0F {
+	case 0x00: // ORB/IRB, unconnected inputs are pulled up.
+		return v.orb&v.ddrb | ^v.ddrb
+	case 0x01, 0x0F: // ORA/IRA, inputs are driven by the PSG.
+		return v.ora&v.ddra | v.psg.Bus()&^v.ddra
+	case 0x02:
+		return v.ddrb
+	case 0x03:
+		return v.ddra
+	case 0x04: // T1C-L
+		v.ifr &^= irqT1
+		return byte(v.t1)
+	case 0x05: // T1C-H
+		return byte(v.t1 >> 8)
+	case 0x06: // T1L-L
+		return byte(v.t1l)
+	case 0x07: // T1L-H
+		return byte(v.t1l >> 8)
+	case 0x08: // T2C-L
+		v.ifr &^= irqT2
+		return byte(v.t2)
+	case 0x09: // T2C-H
+		return byte(v.t2 >> 8)
+	case 0x0A:
+		return v.sr
+	case 0x0B:
+		return v.acr
+	case 0x0C:
+		return v.pcr
+	case 0x0D:
+		return v.flags()
+	default: // IER
+		return v.ier | 0x80
+	}
+}
+
+// Write writes a register (0x00-0x0F).
+func (v *VIA) Write(reg, b byte) {
+	v.run(v.clock.Cycles())
+
+	switch reg & 0x0F {
+	case 0x00:
+		v.orb = b
+		v.control()
+	case 0x01, 0x0F:
+		v.ora = b
+	case 0x02:
+		v.ddrb = b
+		v.control()
+	case 0x03:
+		v.ddra = b
+	case 0x04, 0x06: // T1L-L
+		v.t1l = v.t1l&0xFF00 | uint16(b)
+	case 0x05: // T1C-H, loads and starts the counter.
+		v.t1l = v.t1l&0x00FF | uint16(b)<<8
+		v.t1, v.t1on = v.t1l, true
+		v.ifr &^= irqT1
+	case 0x07: // T1L-H
+		v.t1l = v.t1l&0x00FF | uint16(b)<<8
+		v.ifr &^= irqT1
+	case 0x08: // T2L-L
+		v.t2l = b
+	case 0x09: // T2C-H, loads and starts the counter.
+		v.t2, v.t2on = uint16(b)<<8|uint16(v.t2l), true
+		v.ifr &^= irqT2
+	case 0x0A:
+		v.sr = b
+	case 0x0B:
+		v.acr = b
+	case 0x0C:
+		v.pcr = b
+	case 0x0D: // Ones clear the flags.
+		v.ifr &^= b & 0x7F
+	default: // IER, bit 7 sets or clears the flags set to one.
+		if b&0x80 != 0 {
+			v.ier |= b & 0x7F
+		} else {
+			v.ier &^= b
+		}
+	}
+}
+
+// IRQ signals, whether an enabled interrupt flag is set.
+func (v *VIA) IRQ() bool {
+	v.run(v.clock.Cycles())
+	return v.flags()&irqAny != 0
+}
+
+// Reset clears the registers and stops the timers, the latches are kept.
+func (v *VIA) Reset() {
+	v.orb, v.ora, v.ddrb, v.ddra = 0, 0, 0, 0
+	v.sr, v.acr, v.pcr, v.ifr, v.ier = 0, 0, 0, 0, 0
+	v.t1on, v.t2on = false, false
+	v.last = v.clock.Cycles()
+	v.psg.Reset()
+}
+
+// flags returns IFR, bit 7 is set, when an enabled flag is set.
+func (v *VIA) flags() byte {
+	if v.ifr&v.ier&0x7F != 0 {
+		return v.ifr | irqAny
+	}
+	return v.ifr
+}
+
+// control passes the port B output lines and the port A data to the PSG.
+func (v *VIA) control() {
+	v.psg.Control(v.orb&v.ddrb|^v.ddrb, v.ora&v.ddra|^v.ddra)
+}
+
+// run counts the timers down to the CPU cycle. A counter passes zero,
+// underflows to 0xFFFF a cycle later and sets its interrupt flag. In
+// free-running mode (ACR bit 6), timer 1 is reloaded from the latch
+// then, the period is latch + 2 cycles.
+func (v *VIA) run(now uint64) {
+	d := int64(now - v.last)
+	v.last = now
+
+	if c := int64(v.t1) - d; c >= 0 {
+		v.t1 = uint16(c)
+	} else {
+		if v.t1on {
+			v.ifr |= irqT1
+		}
+		if v.acr&0x40 != 0 {
+			period := int64(v.t1l) + 2
+			if r := (-c - 1) % period; r == 0 {
+				v.t1 = 0xFFFF
+			} else {
+				v.t1 = uint16(int64(v.t1l) - r + 1)
+			}
+		} else {
+			v.t1, v.t1on = uint16(c), false
+		}
+	}
+
+	if c := int64(v.t2) - d; c >= 0 {
+		v.t2 = uint16(c)
+	} else {
+		if v.t2on {
+			v.ifr |= irqT2
+		}
+		v.t2, v.t2on = uint16(c), false
+	}
+}
@@ -4,91 +4,319 @@ package emu
 
 import (
 	"bytes"
-	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"retro/emu/config"
 	"retro/emu/device/diskette"
 	"retro/emu/virtual"
 )
 
+type (
+	// disks keeps track of the origin of images inserted into the drives.
+	disks struct {
+		conf  *config.Config
+		card  *diskette.Card
+		paths [2]string
+		last  [2]string // origin of the ejected images
+		set   []string  // images of the selected disk set
+		pos   [2]int    // index of the set image in (or ejected from) the drives, or -1
+	}
+)
+
 // insertDisks mounts disk images, if any, into drives in slot #6.
-func insertDisks(conf *config.Config, bridge *virtual.Bridge) error {
+func insertDisks(conf *config.Config, bridge *virtual.Bridge) (*disks, error) {
+	d := &disks{conf: conf, pos: [2]int{-1, -1}}
+
+	if name := conf.Disk.Set; name != "" {
+		if d.set = conf.DiskSets[name]; len(d.set) == 0 {
+			return d, fmt.Errorf("disk set %q not configured", name)
+		}
+	}
 
 	slot := bridge.Memory().Slot(6)
 	card, ok := slot.(*diskette.Card)
 	if !ok {
-		return nil
+		return d, nil
 	}
+	d.card = card
 
 	paths := []string{
 		conf.Disk.Drive1,
 		conf.Disk.Drive2,
 	}
+	protect := []bool{
+		conf.Disk.Drive1Protect,
+		conf.Disk.Drive2Protect,
+	}
 
 	for i := 0; i < 2; i++ {
 		if len(paths[i]) == 0 {
 			continue
 		}
 
-		stream, err := openImageStream(paths[i], conf.Version)
+		image, err := LoadImage(paths[i], conf)
 		if err != nil {
-			return err
+			return d, err
 		}
 
-		drv := card.Drive(i)
-		drv.Insert(diskette.NewStandardImage().MustLoad(stream))
+		card.Protect(i, protect[i] || isReadOnly(paths[i], image))
+		card.Insert(i, image)
+		d.paths[i], d.pos[i] = paths[i], d.index(paths[i])
+	}
+	return d, nil
+}
 
-		if closer, ok := stream.(io.Closer); ok {
-			_ = closer.Close()
+// LoadImage reads a disk image from a file or URL, detects its format
+// and sector order, unless the sector order is set by configuration.
+func LoadImage(path string, conf *config.Config) (*diskette.Image, error) {
+	stream, err := openImageStream(path, conf.Version)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := stream.(io.Closer); ok {
+		defer func() { _ = closer.Close() }()
+	}
+
+	data, err := io.ReadAll(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	// Images packed in .gz or .zip archives.
+	name, data, err := unpack(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := diskette.Sniff(name, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	image := diskette.NewStandardImage()
+	switch format {
+	case diskette.FormatWOZ:
+		err = image.LoadWOZ(bytes.NewReader(data))
+	case diskette.Format2IMG:
+		err = image.Load2IMG(bytes.NewReader(data))
+	case diskette.FormatD13:
+		err = image.LoadD13(bytes.NewReader(data))
+	case diskette.FormatNIB:
+		err = image.LoadNIB(bytes.NewReader(data))
+	default:
+		return loadSectorImage(path, name, data, conf)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return image, nil
+}
+
+// loadSectorImage loads a 16 sector image in the detected sector order.
+func loadSectorImage(path, name string, data []byte, conf *config.Config) (*diskette.Image, error) {
+	var err error
+
+	order := diskette.DetectOrder(name, data)
+	if name := conf.Disk.Order; name != "" && name != "auto" {
+		if order, err = diskette.ParseOrder(name); err != nil {
+			return nil, err
 		}
 	}
-	return nil
+
+	image := diskette.NewOrderedImage(order)
+	if err = image.Load(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return image, nil
 }
 
-func openImageStream(path string, userAgent string) (io.Reader, error) {
-	stream, err := openRemoteImage(path, userAgent)
+// insert replaces the image in the drive, while the machine is running.
+// A modified image in the drive is written back first.
+func (d *disks) insert(num int, path string) error {
+	if d.card == nil {
+		return nil
+	}
+	image, err := LoadImage(path, d.conf)
 	if err != nil {
-		return openLocalImage(path)
+		return err
+	}
+	if err = d.eject(num); err != nil {
+		return err
 	}
-	return stream, nil
+	d.card.Protect(num, isReadOnly(path, image))
+	d.card.Insert(num, image)
+	d.paths[num&0x01], d.pos[num&0x01] = path, d.index(path)
+	return nil
 }
 
-func openLocalImage(path string) (io.Reader, error) {
-	return os.Open(path)
+// eject writes a modified image back and removes it from the drive.
+func (d *disks) eject(num int) error {
+	if d.card == nil || d.card.Image(num) == nil {
+		return nil
+	}
+	if err := d.save(num); err != nil {
+		return err
+	}
+	d.card.Eject(num)
+	d.last[num&0x01], d.paths[num&0x01] = d.paths[num&0x01], ""
+	return nil
 }
 
-func openRemoteImage(path string, userAgent string) (io.Reader, error) {
-	uri, err := url.Parse(path)
-	if err != nil {
-		return nil, err
+// toggle ejects the image from the drive, or inserts the image ejected
+// before, and returns the path of the image, that is in the drive now.
+func (d *disks) toggle(num int) (string, error) {
+	if d.card == nil {
+		return "", nil
 	}
+	if d.card.Image(num) != nil {
+		return "", d.eject(num)
+	}
+	if path := d.last[num&0x01]; path != "" {
+		return path, d.insert(num, path)
+	}
+	return "", nil
+}
+
+// next inserts the next image of the disk set into the drive and
+// returns its path. The images are inserted one after another. A drive
+// without an image of the set gets the image following the one in the
+// other drive, or the first one. An image failing to load is skipped
+// with the next call.
+func (d *disks) next(num int) (string, error) {
+	if len(d.set) == 0 {
+		return "", errors.New("no disk set selected")
+	}
+	pos := d.pos[num&0x01]
+	if pos < 0 {
+		pos = d.pos[^num&0x01]
+	}
+	pos = (pos + 1) % len(d.set)
+
+	d.pos[num&0x01] = pos
+	return d.set[pos], d.insert(num, d.set[pos])
+}
+
+// index returns the index of the image in the disk set, or -1.
+func (d *disks) index(path string) int {
+	for i, p := range d.set {
+		if p == path {
+			return i
+		}
+	}
+	return -1
+}
+
+// protect toggles the write-protect state of a drive and returns it.
+// Images, that can not be saved, stay write-protected.
+func (d *disks) protect(num int) (bool, error) {
+	if d.card == nil {
+		return false, nil
+	}
+	state := !d.card.Protected(num)
+	path, image := d.paths[num&0x01], d.card.Image(num)
+
+	if !state && image != nil && isReadOnly(path, image) {
+		return true, fmt.Errorf("%s: read-only image, can not be saved", path)
+	}
+	d.card.Protect(num, state)
+	return state, nil
+}
+
+// flush writes all modified images back to their origin.
+func (d *disks) flush() error {
+	if d.card == nil {
+		return nil
+	}
+	return errors.Join(d.save(0), d.save(1))
+}
+
+func (d *disks) save(num int) error {
+	image := d.card.Image(num)
+	if image == nil || !image.Dirty() {
+		return nil
+	}
+	return SaveImage(d.paths[num&0x01], image)
+}
 
-	req, err := http.NewRequest("GET", uri.String(), nil)
+// SaveImage atomically replaces the image file. Writes to a temporary
+// file next to the original first, then renames it to the original.
+func SaveImage(path string, image *diskette.Image) error {
+	if isRemote(path) {
+		return fmt.Errorf("%s: fetched images are not saved", path)
+	}
+	if isArchive(path) {
+		return fmt.Errorf("%s: archived images are not saved", path)
+	}
+	info, err := os.Stat(path)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	req.Header.Set("User-Agent", fmt.Sprintf("retro %s", userAgent))
-	req.Header.Set("Accept-Encoding", "gzip")
 
-	res, err := (&http.Client{}).Do(req)
+	dir, name := filepath.Split(path)
+	file, err := os.CreateTemp(dir, "."+name+".*")
 	if err != nil {
-		return nil, err
+		return err
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+
+	// Undecodable tracks do not keep the other tracks from being saved.
+	_, lost := image.WriteTo(file)
+	if lost != nil && !errors.Is(lost, diskette.ErrTrack) {
+		_ = file.Close()
+		return fmt.Errorf("%s: %w", path, lost)
 	}
-	defer res.Body.Close()
+	if err = file.Chmod(info.Mode().Perm()); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(file.Name(), path); err != nil {
+		return err
+	}
+	if lost != nil {
+		return fmt.Errorf("%s: %w", path, lost)
+	}
+	return nil
+}
 
-	// Do not pass the body reader. Read all first.
-	body, err := io.ReadAll(res.Body)
+// isReadOnly signals, whether the image should be write-protected: images
+// fetched via HTTP, archived images, files without write permission,
+// images in a format, that can not be written (WOZ), and images flagged
+// as write protected.
+func isReadOnly(path string, image *diskette.Image) bool {
+	if isRemote(path) || isArchive(path) || !image.Writable() || image.Meta()["write_protected"] == "1" {
+		return true
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
 	if err != nil {
-		return nil, err
+		return true
 	}
-	reader := bytes.NewReader(body)
+	_ = file.Close()
+	return false
+}
+
+func isRemote(path string) bool {
+	uri, err := url.Parse(path)
+	return err == nil && (uri.Scheme == "http" || uri.Scheme == "https")
+}
 
-	if res.Header.Get("Content-Encoding") == "gzip" {
-		return gzip.NewReader(reader)
+func openImageStream(path string, userAgent string) (io.Reader, error) {
+	if isRemote(path) {
+		return openRemoteImage(path, userAgent)
 	}
-	return reader, nil
+	return openLocalImage(path)
+}
+
+func openLocalImage(path string) (io.Reader, error) {
+	return os.Open(path)
 }
@@ -6,6 +6,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"os"
 	"os/signal"
 	"retro/emu/config"
@@ -14,6 +15,7 @@ import (
 	"retro/gui"
 	"strings"
 	"syscall"
+	"time"
 )
 
 // Run runs an Apple II emulation.
@@ -41,17 +43,52 @@ func Run(ctx context.Context, conf *config.Config) (err error) {
 		make(chan input.KeyInput, 0x1000),
 		make(chan input.MouseButton),
 		make(chan input.CursorPos),
+		make(chan input.FileDrop),
 	)
 	keyMap := input.NewKeyMap()
 
+	// Speaker sound output.
+	sink, err := openAudio(conf)
+	if err != nil {
+		return err
+	}
+	if sink != nil {
+		defer func() {
+			err = errors.Join(err, sink.Close())
+		}()
+	}
+
 	// The emulator.
-	machine := virtual.NewAppleTwo(conf, keyMap, channels)
+	machine := virtual.NewAppleTwo(conf, keyMap, channels, sink)
 	bridge := machine.Bridge()
 
-	if err = insertDisks(conf, bridge); err != nil {
+	disks, err := insertDisks(conf, bridge)
+	if err != nil {
 		return err
 	}
 
+	// Write modified disk images back, when leaving.
+	defer func() {
+		err = errors.Join(err, disks.flush())
+	}()
+
+	// Cassette tape and recording.
+	tape, err := insertTape(conf, bridge)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, tape.Close())
+	}()
+
+	// Periodically, when configured.
+	var autosave <-chan time.Time
+	if conf.Disk.Autosave > 0 {
+		ticker := time.NewTicker(time.Duration(conf.Disk.Autosave) * time.Second)
+		defer ticker.Stop()
+		autosave = ticker.C
+	}
+
 	// Emulator power on.
 	errCh := make(chan error)
 	go func() { errCh <- machine.PowerOn(ctx) }()
@@ -80,11 +117,34 @@ func Run(ctx context.Context, conf *config.Config) (err error) {
 		}
 	}
 
+	// Disk swap reporting.
+	swap := func(num int, fn func(int) (string, error)) {
+		path, err := fn(num)
+		switch {
+		case err != nil:
+			log.Printf("drive %d: %s", num+1, err)
+		case path == "":
+			log.Printf("drive %d: empty", num+1)
+		default:
+			log.Printf("drive %d: %s", num+1, path)
+		}
+	}
+
+	// Write protection reporting.
+	protect := func(num int) {
+		state, err := disks.protect(num)
+		if err != nil {
+			log.Printf("drive %d: %s", num+1, err)
+		}
+		log.Printf("drive %d write-protected: %t", num+1, state)
+	}
+
 	// Paddle positioning.
 	aspectW := 255 / float64(props.Width)
 	aspectH := 255 / float64(props.Height)
 
 	mem := bridge.Memory()
+	paddles := bridge.Paddles()
 
 	// Main loop.
 	for {
@@ -97,16 +157,26 @@ func Run(ctx context.Context, conf *config.Config) (err error) {
 				machine.Reset()
 			case key.IsCtrlV():
 				go paste(win.Clipboard())
+			case key.IsCtrlShift('1'):
+				protect(0)
+			case key.IsCtrlShift('2'):
+				protect(1)
+			case key.IsCtrlAlt('1'):
+				swap(0, disks.toggle)
+			case key.IsCtrlAlt('2'):
+				swap(1, disks.toggle)
+			case key.IsCtrlShiftAlt('1'):
+				swap(0, disks.next)
+			case key.IsCtrlShiftAlt('2'):
+				swap(1, disks.next)
 			default:
 				channels.KeyBuffer() <- key
 			}
 
-		// Paddle move, requires tweaked ROM at 0xFB28 (see garage.go).
+		// Paddle move, the mouse turns paddle 0 and 1.
 		case pos := <-channels.CursorPos():
-			x := byte(pos.X() * aspectW)
-			y := byte(pos.Y() * aspectH)
-			mem.Write(0x64, 0xC0, x)
-			mem.Write(0x65, 0xC0, y)
+			paddles.Move(0, byte(pos.X()*aspectW))
+			paddles.Move(1, byte(pos.Y()*aspectH))
 
 		// Mouse button.
 		case but := <-channels.MouseButton():
@@ -114,6 +184,28 @@ func Run(ctx context.Context, conf *config.Config) (err error) {
 			on := map[bool]byte{true: 0x80, false: 0x00}
 			mem.Write(0x61+no, 0xC0, on[but.IsPressed()])
 
+		// Disk images dropped onto the window. One image goes into drive 1,
+		// when dropped onto the left half, into drive 2 on the right half.
+		// Two images go into both drives.
+		case drop := <-channels.FileDrop():
+			paths := drop.Paths()
+			num := 0
+			if len(paths) == 1 && drop.X() >= float64(props.Width)/2 {
+				num = 1
+			}
+			for i := 0; i < len(paths) && num+i < 2; i++ {
+				path := paths[i]
+				swap(num+i, func(num int) (string, error) {
+					return path, disks.insert(num, path)
+				})
+			}
+
+		// Disk image write-back.
+		case <-autosave:
+			if err := disks.flush(); err != nil {
+				log.Print(err)
+			}
+
 		// Machine or window error.
 		case err = <-errCh:
 			if err != nil && err.Error() != "context canceled" {
@@ -0,0 +1,32 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package input
+
+type (
+	// FileDrop is emitted, when files are dropped onto the window.
+	FileDrop struct {
+		paths []string
+		x     float64
+		y     float64
+	}
+)
+
+// NewFileDrop creates a new drop event at the cursor position.
+func NewFileDrop(paths []string, x, y float64) FileDrop {
+	return FileDrop{paths, x, y}
+}
+
+// Paths returns the paths of the dropped files.
+func (d FileDrop) Paths() []string {
+	return d.paths
+}
+
+// X returns the x coordinate.
+func (d FileDrop) X() float64 {
+	return d.x
+}
+
+// Y returns the y coordinate.
+func (d FileDrop) Y() float64 {
+	return d.y
+}
@@ -21,6 +21,24 @@ func (e KeyInput) IsCtrlShiftR() bool {
 	return e.key == 0x52 && (e.act == 1 || e.act == 2) && e.mod == 3
 }
 
+// IsCtrlShift signals when CTRL-SHIFT and the key (an upper case
+// letter or a digit) are pressed.
+func (e KeyInput) IsCtrlShift(key byte) bool {
+	return e.key == int(key) && (e.act == 1 || e.act == 2) && e.mod == 3
+}
+
+// IsCtrlAlt signals when CTRL-ALT and the key (an upper case
+// letter or a digit) are pressed.
+func (e KeyInput) IsCtrlAlt(key byte) bool {
+	return e.key == int(key) && (e.act == 1 || e.act == 2) && e.mod == 6
+}
+
+// IsCtrlShiftAlt signals when CTRL-SHIFT-ALT and the key (an upper
+// case letter or a digit) are pressed.
+func (e KeyInput) IsCtrlShiftAlt(key byte) bool {
+	return e.key == int(key) && (e.act == 1 || e.act == 2) && e.mod == 7
+}
+
 // IsCtrlV signals when CTRL-V is pressed.
 func (e KeyInput) IsCtrlV() bool {
 	return e.key == 0x56 && (e.act == 1 || e.act == 2) && e.mod == 2
@@ -11,11 +11,30 @@ type (
 		Slot(num byte)
 	}
 
+	// Clock provides the number of elapsed CPU cycles
+	// to devices, that depend on accurate timing.
+	Clock interface {
+		Cycles() uint64
+	}
+
+	// Interrupter is a device, that can request an interrupt (IRQ).
+	Interrupter interface {
+		IRQ() bool
+	}
+
+	// Flusher is a device, that passes its output on in batches,
+	// e.g. sound samples rendered up to the current CPU cycle.
+	Flusher interface {
+		Flush()
+	}
+
 	// Manager delegates memory access.
 	Manager struct {
-		mem  Memory
-		dev  []Device
-		list []Device
+		mem   Memory
+		dev   []Device
+		list  []Device
+		irq   []Interrupter
+		flush []Flusher
 	}
 )
 
@@ -26,6 +45,7 @@ func NewManager(mem Memory, devices ...Device) *Manager {
 	// 0-7 reserved for slotted devices.
 	m.dev = make([]Device, 8)
 	m.dev = append(m.dev, devices...)
+	m.hooks()
 
 	return m
 }
@@ -44,6 +64,21 @@ func (m *Manager) Mount(slot byte, device Device) {
 			m.list = append(m.list, m.dev[i])
 		}
 	}
+	m.hooks()
+}
+
+// hooks collects the devices requesting interrupts or flushes.
+func (m *Manager) hooks() {
+	m.irq, m.flush = m.irq[:0], m.flush[:0]
+
+	for _, dev := range m.dev {
+		if irq, ok := dev.(Interrupter); ok {
+			m.irq = append(m.irq, irq)
+		}
+		if flush, ok := dev.(Flusher); ok {
+			m.flush = append(m.flush, flush)
+		}
+	}
 }
 
 // Slot returns device in slot or nil.
@@ -73,6 +108,23 @@ func (m *Manager) Write(lo, hi, b byte) {
 	}
 }
 
+// IRQ signals, whether a device requests an interrupt.
+func (m *Manager) IRQ() bool {
+	for _, dev := range m.irq {
+		if dev.IRQ() {
+			return true
+		}
+	}
+	return false
+}
+
+// Flush lets the devices pass their output on.
+func (m *Manager) Flush() {
+	for _, dev := range m.flush {
+		dev.Flush()
+	}
+}
+
 // Reset resets all devices.
 func (m *Manager) Reset() {
 	for _, dev := range m.list {
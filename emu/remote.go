@@ -0,0 +1,209 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package emu
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+type (
+	// cache keeps copies of fetched images in ~/.cache/retro,
+	// the file names are derived from the URL.
+	cache struct {
+		path string
+	}
+
+	// cacheMeta is stored next to a cached image, for revalidation.
+	cacheMeta struct {
+		URL          string `json:"url"`
+		ETag         string `json:"etag,omitempty"`
+		LastModified string `json:"last-modified,omitempty"`
+	}
+)
+
+// errNotModified is returned, when the cached copy is still valid.
+var errNotModified = errors.New("not modified")
+
+// newCache returns the cache entry of the URL. The path
+// is empty, when there is no cache directory.
+func newCache(uri string) *cache {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return &cache{}
+	}
+	sum := sha256.Sum256([]byte(uri))
+	return &cache{path: filepath.Join(dir, "retro", hex.EncodeToString(sum[:16]))}
+}
+
+// meta returns the revalidation data of the cached copy, if any.
+func (c *cache) meta() cacheMeta {
+	meta := cacheMeta{}
+	if c.path == "" {
+		return meta
+	}
+	if data, err := os.ReadFile(c.path + ".json"); err == nil {
+		_ = json.Unmarshal(data, &meta)
+	}
+	return meta
+}
+
+// data returns the cached copy.
+func (c *cache) data() ([]byte, error) {
+	if c.path == "" {
+		return nil, errors.New("no cache directory")
+	}
+	return os.ReadFile(c.path)
+}
+
+// store replaces the cached copy and its revalidation data.
+func (c *cache) store(data []byte, meta cacheMeta) error {
+	if c.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	if meta.ETag == "" && meta.LastModified == "" {
+		_ = os.Remove(c.path + ".json")
+		return writeFile(c.path, data)
+	}
+	js, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if err = writeFile(c.path, data); err != nil {
+		return err
+	}
+	return writeFile(c.path+".json", js)
+}
+
+// openRemoteImage fetches the image, unless the cached copy is still
+// valid: the server is asked with the ETag and Last-Modified values of
+// the cached copy. When the server is not reachable, the cached copy
+// is used. Without the cached copy, the image is fetched again.
+func openRemoteImage(path string, userAgent string) (io.Reader, error) {
+	c := newCache(path)
+	meta := c.meta()
+
+	data, err := fetchRemoteImage(path, userAgent, &meta)
+	if errors.Is(err, errNotModified) {
+		if reader, cerr := openCachedImage(c, err); cerr == nil {
+			return reader, nil
+		}
+		// The cached copy is gone, fetch without revalidation.
+		meta = cacheMeta{}
+		data, err = fetchRemoteImage(path, userAgent, &meta)
+	}
+	if err != nil {
+		reader, cerr := openCachedImage(c, err)
+		if cerr != nil {
+			return nil, err
+		}
+		log.Printf("%s, using the cached copy", err)
+		return reader, nil
+	}
+
+	if err = c.store(data, meta); err != nil {
+		log.Printf("%s: not cached: %s", path, err)
+	}
+	return bytes.NewReader(data), nil
+}
+
+func openCachedImage(c *cache, cause error) (io.Reader, error) {
+	data, err := c.data()
+	if err != nil {
+		return nil, fmt.Errorf("%w, %w", cause, err)
+	}
+	return bytes.NewReader(data), nil
+}
+
+// fetchRemoteImage downloads the image. The revalidation data is sent
+// along and updated from the response.
+func fetchRemoteImage(path string, userAgent string, meta *cacheMeta) ([]byte, error) {
+	uri, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", uri.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", fmt.Sprintf("retro %s", userAgent))
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	if meta.URL == path {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+
+	res, err := (&http.Client{}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotModified:
+		return nil, errNotModified
+	case res.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s: HTTP status %s", path, res.Status)
+	}
+
+	// Do not pass the body reader. Read all first.
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body, err = io.ReadAll(reader); err != nil {
+			return nil, err
+		}
+	}
+
+	meta.URL = path
+	meta.ETag = res.Header.Get("ETag")
+	meta.LastModified = res.Header.Get("Last-Modified")
+
+	return body, nil
+}
+
+// writeFile atomically replaces the file.
+func writeFile(path string, data []byte) error {
+	dir, name := filepath.Split(path)
+	file, err := os.CreateTemp(dir, "."+name+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+
+	if _, err = file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
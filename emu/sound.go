@@ -0,0 +1,39 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package emu
+
+import (
+	"log"
+	"retro/emu/audio"
+	"retro/emu/config"
+	"retro/emu/virtual"
+)
+
+// openAudio opens the outputs of the speaker sound: the playback client
+// and the WAV file, when configured. The emulator runs without sound,
+// when no playback client is available, the sink is nil then.
+func openAudio(conf *config.Config) (audio.Sink, error) {
+	var sinks []audio.Sink
+
+	if path := conf.Audio.WAV; path != "" {
+		wav, err := audio.NewWAV(path, virtual.SampleRate)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, wav)
+	}
+
+	if name := conf.Audio.Output; name != "" && name != "none" {
+		latency := virtual.SampleRate * max(conf.Audio.Latency, 1) / 1000
+		player, err := audio.NewPlayer(name, virtual.SampleRate, latency)
+		if err != nil {
+			log.Printf("audio: %s", err)
+		} else {
+			sinks = append(sinks, player)
+		}
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	return audio.Join(sinks...), nil
+}
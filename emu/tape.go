@@ -0,0 +1,75 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package emu
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"retro/emu/audio"
+	"retro/emu/config"
+	"retro/emu/device/builtin"
+	"retro/emu/virtual"
+	"strings"
+)
+
+// insertTape inserts the tape into the cassette player and starts the
+// recording, when configured. The returned sink is to be closed on exit.
+func insertTape(conf *config.Config, bridge *virtual.Bridge) (audio.Sink, error) {
+	cassette := bridge.Cassette()
+
+	if path := conf.Tape.Play; path != "" {
+		tape, err := LoadTape(path)
+		if err != nil {
+			return nil, err
+		}
+		cassette.Insert(tape)
+	}
+
+	if path := conf.Tape.Record; path != "" {
+		wav, err := audio.NewWAV(path, virtual.SampleRate)
+		if err != nil {
+			return nil, err
+		}
+		cassette.Record(wav, virtual.SampleRate)
+		return wav, nil
+	}
+	return audio.Join(), nil
+}
+
+// LoadTape reads a tape from a file: a WAV or AIFF recording, a tokenized
+// Applesoft program (.bas), encoded as the length and the program record
+// for LOAD, or raw data (any other file), encoded as one record.
+func LoadTape(path string) (*builtin.Tape, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%s: empty tape", path)
+	}
+
+	samples, rate, err := audio.Decode(data)
+	if err == nil {
+		return builtin.NewTape(samples, rate), nil
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".wav", ".aif", ".aiff":
+		return nil, fmt.Errorf("%s: %w", path, err)
+	case ".bas":
+		return encodeApplesoft(path, data)
+	}
+	return builtin.EncodeTape(data), nil
+}
+
+// encodeApplesoft encodes a program as written by SAVE: the length and
+// a flag (bit 7 runs the program after LOAD), then the program and
+// the byte following it.
+func encodeApplesoft(path string, data []byte) (*builtin.Tape, error) {
+	if len(data) > 0x9600-0x0801 {
+		return nil, fmt.Errorf("%s: program too large", path)
+	}
+	head := []byte{byte(len(data)), byte(len(data) >> 8), 0x00}
+	return builtin.EncodeTape(head, append(data, 0x00)), nil
+}
@@ -3,6 +3,7 @@
 package virtual
 
 import (
+	"retro/emu/device/builtin"
 	"retro/emu/device/render"
 	"retro/emu/input"
 	"retro/emu/memory"
@@ -13,6 +14,8 @@ type (
 	Bridge struct {
 		manager  *memory.Manager
 		driver   *render.Driver
+		paddles  *builtin.Paddles
+		cassette *builtin.Cassette
 		keyMap   *input.KeyMap
 		channels *Channels
 	}
@@ -22,10 +25,12 @@ type (
 func NewBridge(
 	manager *memory.Manager,
 	driver *render.Driver,
+	paddles *builtin.Paddles,
+	cassette *builtin.Cassette,
 	keyMap *input.KeyMap,
 	channels *Channels,
 ) *Bridge {
-	return &Bridge{manager, driver, keyMap, channels}
+	return &Bridge{manager, driver, paddles, cassette, keyMap, channels}
 }
 
 // Memory is system memory manager unit.
@@ -38,6 +43,16 @@ func (b *Bridge) Renderer() *render.Driver {
 	return b.driver
 }
 
+// Paddles returns the analog paddles.
+func (b *Bridge) Paddles() *builtin.Paddles {
+	return b.paddles
+}
+
+// Cassette returns the cassette port.
+func (b *Bridge) Cassette() *builtin.Cassette {
+	return b.cassette
+}
+
 // Reset resets all peripheral cards.
 func (b *Bridge) Reset() {
 	b.manager.Reset()
@@ -9,10 +9,11 @@ import (
 type (
 	// Channels transports peripheral I/O event channels.
 	Channels struct {
-		keyCh chan input.KeyInput
-		bufCh chan input.KeyInput
-		butCh chan input.MouseButton
-		posCh chan input.CursorPos
+		keyCh  chan input.KeyInput
+		bufCh  chan input.KeyInput
+		butCh  chan input.MouseButton
+		posCh  chan input.CursorPos
+		dropCh chan input.FileDrop
 	}
 )
 
@@ -22,8 +23,9 @@ func NewChannels(
 	bufCh chan input.KeyInput,
 	butCh chan input.MouseButton,
 	posCh chan input.CursorPos,
+	dropCh chan input.FileDrop,
 ) *Channels {
-	return &Channels{keyCh, bufCh, butCh, posCh}
+	return &Channels{keyCh, bufCh, butCh, posCh, dropCh}
 }
 
 // KeyInput returns the key press/release event channel.
@@ -45,3 +47,8 @@ func (c *Channels) MouseButton() chan input.MouseButton {
 func (c *Channels) CursorPos() chan input.CursorPos {
 	return c.posCh
 }
+
+// FileDrop returns the file drop channel.
+func (c *Channels) FileDrop() chan input.FileDrop {
+	return c.dropCh
+}
@@ -0,0 +1,30 @@
+// MIT License · Daniel T. Gorski · dtg [at] lengo [dot] org · 12/2023
+
+package virtual
+
+import (
+	"sync/atomic"
+)
+
+type (
+	// Clock counts the CPU cycles since power on. It is the
+	// time base for devices, that depend on accurate timing.
+	Clock struct {
+		cycles atomic.Uint64
+	}
+)
+
+// NewClock creates a new CPU cycle counter.
+func NewClock() *Clock {
+	return &Clock{}
+}
+
+// Tick advances the clock by the number of cycles.
+func (c *Clock) Tick(cycles uint) {
+	c.cycles.Add(uint64(cycles))
+}
+
+// Cycles returns the number of CPU cycles since power on.
+func (c *Clock) Cycles() uint64 {
+	return c.cycles.Load()
+}
@@ -3,20 +3,37 @@
 package virtual
 
 import (
+	"fmt"
 	cpu "github.com/dtgorski/m6502"
+	"os"
+	"retro/emu/audio"
 	"retro/emu/config"
 	"retro/emu/device/builtin"
 	"retro/emu/device/diskette"
 	"retro/emu/device/language"
+	"retro/emu/device/mockingboard"
 	"retro/emu/device/render"
 	"retro/emu/files"
 	"retro/emu/input"
 	"retro/emu/memory"
 )
 
-// NewAppleTwo creates an Apple II setup.
-func NewAppleTwo(conf *config.Config, keyMap *input.KeyMap, channels *Channels) *Machine {
+// SampleRate is the number of sound frames per second.
+const SampleRate = 44100
+
+// NewAppleTwo creates an Apple II setup. The sink receives the mixed
+// sound of the speaker and the Mockingboard. Without a sink (nil), the
+// sound is not rendered at all.
+func NewAppleTwo(conf *config.Config, keyMap *input.KeyMap, channels *Channels, sink audio.Sink) *Machine {
 	hz := int(conf.MHz * 1024 * 1024)
+	clock := NewClock()
+
+	// Mixer inputs for the sound devices.
+	input := func() builtin.Sink { return nil }
+	if sink != nil {
+		mixer := audio.NewMixer(sink)
+		input = func() builtin.Sink { return mixer.Input() }
+	}
 
 	// Main 64KB memory segment.
 	mem := memory.NewMemory()
@@ -24,10 +41,12 @@ func NewAppleTwo(conf *config.Config, keyMap *input.KeyMap, channels *Channels)
 	// Display driver and I/O page (soft switches)
 	renderer := render.NewDriver(createRenderModes(conf, mem.DMA()))
 	keyboard := builtin.NewKeyboard(mem)
-	paddle := builtin.NewPaddle(mem)
+	paddle := builtin.NewPaddle(mem, clock)
+	speaker := builtin.NewSpeaker(clock, hz, SampleRate, input())
+	cassette := builtin.NewCassette(clock)
 
 	// Delegates reads/writes to devices (I/O page, slots).
-	mmu := memory.NewManager(mem, renderer, keyboard, paddle)
+	mmu := memory.NewManager(mem, renderer, keyboard, paddle, speaker, cassette)
 
 	// Onboard ROM, load Applesoft Basic and Monitor.
 	mem.MustLoad(0xF800, files.MustOpen(files.ROM_APPLESOFT_BASIC_MON_F800))
@@ -51,21 +70,36 @@ func NewAppleTwo(conf *config.Config, keyMap *input.KeyMap, channels *Channels)
 		0x03, 0x08, // $AF - $B0 | Pointer to end of Applesoft program
 	})
 
-	// Tweak ROM, modify paddle wait routine.
-	copy(mem.DMA()[0xFB28:], []byte{
-		0xA8, 0x60, // TAY, RTS
-	})
-
 	// Slot #0, mount Language Card.
 	mmu.Mount(0, language.NewCard())
 
-	// Slot #6, mount interface ROM only when disk image(s) provided.
-	if len(conf.Disk.Drive1) > 0 || len(conf.Disk.Drive2) > 0 {
-		card := diskette.NewCard(files.MustLoad(files.ROM_APPLE_DISK_II_16))
-		mmu.Mount(6, card)
+	// Slot #6, mount Disk II interface, disks can be inserted any time.
+	mmu.Mount(6, diskette.NewCard(mustLoadDiskROM(conf.Disk.ROM), clock))
+
+	// Slot #4 usually, mount Mockingboard, when configured.
+	if slot := conf.Audio.Mockingboard; slot != 0 {
+		if slot < 1 || slot > 7 || slot == 6 {
+			panic(fmt.Sprintf("mockingboard: slot %d is not available", slot))
+		}
+		mmu.Mount(byte(slot), mockingboard.NewCard(clock, hz, SampleRate, input()))
 	}
 
-	return NewMachine(NewBridge(mmu, renderer, keyMap, channels), cpu.New(mmu), hz)
+	return NewMachine(NewBridge(mmu, renderer, paddle, cassette, keyMap, channels), cpu.New(mmu), clock, hz)
+}
+
+// mustLoadDiskROM loads the Disk II boot ROM, the built-in one by default.
+func mustLoadDiskROM(path string) []byte {
+	if path == "" {
+		return files.MustLoad(files.ROM_APPLE_DISK_II_16)
+	}
+	rom, err := os.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+	if len(rom) != 0x100 {
+		panic(fmt.Sprintf("disk ROM %s: size %d, expected 256 bytes", path, len(rom)))
+	}
+	return rom
 }
 
 // createRenderModes creates rendering modes.
@@ -18,6 +18,7 @@ type (
 		PC(lo, hi byte)
 		PCL() byte
 		PCH() byte
+		IRQ()
 		Reset()
 		Step() (cycles uint, err error)
 	}
@@ -26,13 +27,14 @@ type (
 	Machine struct {
 		bridge *Bridge
 		cpu    CPU
-		clock  int
+		clock  *Clock
+		hz     int
 	}
 )
 
 // NewMachine creates a new Machine.
-func NewMachine(bridge *Bridge, cpu CPU, hz int) *Machine {
-	return &Machine{bridge, cpu, hz}
+func NewMachine(bridge *Bridge, cpu CPU, clock *Clock, hz int) *Machine {
+	return &Machine{bridge, cpu, clock, hz}
 }
 
 // Bridge returns the Machine's Bridge.
@@ -72,6 +74,7 @@ func (m *Machine) PowerOn(ctx context.Context) (err error) {
 
 	batch := 1000
 	cycles := uint(0)
+	mem := m.bridge.Memory()
 
 loop:
 	if ctx.Err() != nil {
@@ -84,17 +87,20 @@ loop:
 	// The overhead associated with the sleep request is not proportional
 	// with the gain (high CPU Load). Let's try in batches, so we can sleep
 	// longer less often and the real CPU Load is reduced. Timing will break.
-	for i := m.clock / batch; i > 0; {
-
-		// Skips dynamically loaded DOS 3.3 wait routine.
-		if m.cpu.PCH() == 0xBA && m.cpu.PCL() == 0x00 {
-			m.cpu.PC(0x10, 0xBA)
-		}
+	for i := m.hz / batch; i > 0; {
 		if cycles, err = m.cpu.Step(); err != nil {
 			return err
 		}
+		m.clock.Tick(cycles)
 		i -= int(cycles)
+
+		// Interrupt requests of cards, e.g. timers.
+		if mem.IRQ() {
+			m.cpu.IRQ()
+		}
 	}
+	// Sound output, rendered up to now.
+	mem.Flush()
 
 	dur := time.Since(now).Nanoseconds()
 
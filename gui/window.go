@@ -111,11 +111,16 @@ func (win *Window) Open() (err error) {
 	posFunc := func(w *glfw.Window, x float64, y float64) {
 		win.channels.CursorPos() <- input.NewCursorPos(x, y)
 	}
+	dropFunc := func(w *glfw.Window, names []string) {
+		x, y := w.GetCursorPos()
+		win.channels.FileDrop() <- input.NewFileDrop(names, x, y)
+	}
 
 	// Install event listeners.
 	win.window.SetKeyCallback(keyFunc)
 	win.window.SetMouseButtonCallback(butFunc)
 	win.window.SetCursorPosCallback(posFunc)
+	win.window.SetDropCallback(dropFunc)
 
 	return nil
 }